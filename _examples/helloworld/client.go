@@ -8,21 +8,15 @@ import (
 
 	pool "github.com/kasiss-liu/grpcpool"
 	"github.com/kasiss-liu/grpcpool/_examples/helloworld/protos/helloworld"
-	"google.golang.org/grpc"
 )
 
-//这里要warp一层 创建client的方法
-func newServerClient(cc grpc.ClientConnInterface) interface{} {
-	ccc := cc.(*grpc.ClientConn)
-	return helloworld.NewHelloWorldClient(ccc)
-}
-
 //一个构造器
 func buildServiceCenter() *pool.ServiceCenter {
 	scb := pool.NewServiceCenterBuilder()
 
+	//helloworld_grpcpool.pb.go 由 protoc-gen-grpcpool 生成
 	builders := map[string]pool.ServerBuilderFunc{
-		"say": newServerClient,
+		helloworld.HelloWorldBuilderName: helloworld.NewHelloWorldBuilder(),
 	}
 
 	err := scb.SetServerWithDefaultOptions("hello", builders, "127.0.0.1:8889")
@@ -51,15 +45,15 @@ func SayHello() (response string, err error) {
 	if !ok {
 		return "",errors.New("unexpected server setting")
 	}
-	client,release,err := c.GetServerClient("say")
+	client,release,err := c.GetServerClient(helloworld.HelloWorldBuilderName)
 	if err != nil {
 		return
 	}
 	defer release()
 	*/
 
-	//在上层确保服务存在的情况下 不再验证server存在 可使用UnsafeGet方法获取
-	client, release, err := sc.UnsafeGet("hello").GetServerClient("say")
+	//使用生成的方法获取类型化的client 集群不存在时返回 pool.ErrClusterNotFound
+	client, release, err := helloworld.GetHelloWorldClient(sc, "hello")
 	if err != nil {
 		return
 	}
@@ -70,7 +64,7 @@ func SayHello() (response string, err error) {
 
 	var resp *helloworld.HelloResp
 	req := &helloworld.HelloRequest{Name: "Jason"}
-	resp, err = client.(helloworld.HelloWorldClient).SayHello(ctx, req)
+	resp, err = client.SayHello(ctx, req)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,43 @@
+// Code generated by protoc-gen-grpcpool. DO NOT EDIT.
+// source: helloworld.proto
+
+package helloworld
+
+import (
+	context "context"
+	grpcpool "github.com/kasiss-liu/grpcpool"
+)
+
+// HelloWorldBuilderName is the name of HelloWorldClient builder in ServerCluster.
+const HelloWorldBuilderName = "helloworld.HelloWorld"
+
+// NewHelloWorldBuilder returns the typed builder of HelloWorldClient.
+func NewHelloWorldBuilder() grpcpool.ServerBuilderFunc {
+	return grpcpool.NewServerBuilder(NewHelloWorldClient)
+}
+
+// RegisterHelloWorldBuilder sets the HelloWorldClient builder on cluster.
+func RegisterHelloWorldBuilder(cluster *grpcpool.ServerCluster) {
+	cluster.SetClientBuilders(map[string]grpcpool.ServerBuilderFunc{
+		HelloWorldBuilderName: NewHelloWorldBuilder(),
+	})
+}
+
+// GetHelloWorldClient returns a HelloWorldClient of the named cluster in sc.
+// release must be called after the calls are done.
+func GetHelloWorldClient(sc *grpcpool.ServiceCenter, cluster string) (HelloWorldClient, func(), error) {
+	server, ok := sc.Get(cluster)
+	if !ok {
+		return nil, nil, grpcpool.ErrClusterNotFound
+	}
+	return grpcpool.GetClient[HelloWorldClient](server, HelloWorldBuilderName)
+}
+
+// GetHelloWorldClientContext is like GetHelloWorldClient but waits for a connection until ctx is done.
+func GetHelloWorldClientContext(ctx context.Context, sc *grpcpool.ServiceCenter, cluster string) (HelloWorldClient, func(), error) {
+	server, ok := sc.Get(cluster)
+	if !ok {
+		return nil, nil, grpcpool.ErrClusterNotFound
+	}
+	return grpcpool.GetClientContext[HelloWorldClient](ctx, server, HelloWorldBuilderName)
+}
@@ -0,0 +1,100 @@
+package pool
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+//defaultScaleDownRounds is used when AutoscaleOptions.ScaleDownRounds is 0
+const defaultScaleDownRounds = 3
+
+//AutoscaleOptions is for adaptive pool size driven by stream utilization
+//pool cap is kept between MinCap and MaxCap , so that the average streams
+//per connection is close to TargetRefCount , callers queued on an exhausted pool count as streams
+type AutoscaleOptions struct {
+	MinCap int
+	MaxCap int
+	//TargetRefCount is the desired average streams per connection
+	TargetRefCount float64
+	//Tolerance is the ratio of TargetRefCount the average may deviate from
+	//before resizing , e.g. 0.2 means resizing out of [0.8*target, 1.2*target]
+	Tolerance float64
+	//Interval between two evaluations
+	Interval time.Duration
+	//ScaleDownRounds is the number of consecutive low evaluations before shrinking
+	//growing happens at once , defaultScaleDownRounds is used when it is 0
+	ScaleDownRounds int
+}
+
+//validate autoscale option if available
+func (a AutoscaleOptions) validate() error {
+	if a.MinCap <= 0 ||
+		a.MaxCap < a.MinCap ||
+		a.TargetRefCount <= 0 ||
+		a.Tolerance < 0 || a.Tolerance >= 1 ||
+		a.Interval <= 0 ||
+		a.ScaleDownRounds < 0 {
+		return ErrOptionValid
+	}
+	return nil
+}
+
+//autoscaler decide pool cap with hysteresis
+type autoscaler struct {
+	opt       AutoscaleOptions
+	lowRounds int
+}
+
+//next return the new cap when streams are on a pool of cap
+//it returns cap when no resizing is needed
+func (a *autoscaler) next(cap int, streams int64) int {
+	target := a.opt.TargetRefCount
+	avg := float64(streams) / float64(cap)
+	desired := int(math.Ceil(float64(streams) / target))
+	if desired < a.opt.MinCap {
+		desired = a.opt.MinCap
+	}
+	if desired > a.opt.MaxCap {
+		desired = a.opt.MaxCap
+	}
+
+	switch {
+	case avg > target*(1+a.opt.Tolerance) && desired > cap:
+		a.lowRounds = 0
+		return desired
+	case avg < target*(1-a.opt.Tolerance) && desired < cap:
+		a.lowRounds++
+		rounds := a.opt.ScaleDownRounds
+		if rounds == 0 {
+			rounds = defaultScaleDownRounds
+		}
+		if a.lowRounds >= rounds {
+			a.lowRounds = 0
+			return desired
+		}
+	default:
+		a.lowRounds = 0
+	}
+	return cap
+}
+
+//autoscaleLoop resize pool by stream utilization until stop is closed
+func (p *GRPCPool) autoscaleLoop(stop <-chan struct{}, opt AutoscaleOptions) {
+	scaler := &autoscaler{opt: opt}
+	ticker := time.NewTicker(opt.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cap := p.Cap()
+			//callers queued on an exhausted pool are demand too
+			streams := p.InUse() + int64(atomic.LoadInt32(&p.waiters))
+			if n := scaler.next(cap, streams); n != cap {
+				_ = p.Resize(n)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestAutoscaleOptionsValid(t *testing.T) {
+	opt := AutoscaleOptions{MinCap: 1, MaxCap: 4, TargetRefCount: 2, Interval: time.Second}
+	assert.Nil(t, opt.validate())
+
+	invalid := opt
+	invalid.MaxCap = 0
+	assert.Equal(t, ErrOptionValid, invalid.validate())
+	invalid = opt
+	invalid.TargetRefCount = 0
+	assert.Equal(t, ErrOptionValid, invalid.validate())
+	invalid = opt
+	invalid.Tolerance = 1
+	assert.Equal(t, ErrOptionValid, invalid.validate())
+	invalid = opt
+	invalid.Interval = 0
+	assert.Equal(t, ErrOptionValid, invalid.validate())
+
+	options, _ := NewOptions(5, []string{"127.0.0.1:8899"})
+	options.Autoscale = &opt
+	assert.Equal(t, ErrOptionValid, options.validate())
+	options.Cap = 4
+	assert.Nil(t, options.validate())
+}
+
+func TestAutoscaler_Next(t *testing.T) {
+	scaler := &autoscaler{opt: AutoscaleOptions{
+		MinCap:          2,
+		MaxCap:          10,
+		TargetRefCount:  10,
+		Tolerance:       0.2,
+		ScaleDownRounds: 2,
+	}}
+
+	//within tolerance
+	assert.Equal(t, 4, scaler.next(4, 45))
+	assert.Equal(t, 4, scaler.next(4, 33))
+	//grow at once
+	assert.Equal(t, 6, scaler.next(4, 55))
+	//never over MaxCap
+	assert.Equal(t, 10, scaler.next(6, 500))
+	//shrink after consecutive low rounds
+	assert.Equal(t, 10, scaler.next(10, 30))
+	assert.Equal(t, 10, scaler.next(10, 100))
+	assert.Equal(t, 10, scaler.next(10, 30))
+	assert.Equal(t, 3, scaler.next(10, 30))
+	//never under MinCap
+	assert.Equal(t, 3, scaler.next(3, 0))
+	assert.Equal(t, 2, scaler.next(3, 0))
+}
+
+func TestGRPCPool_Autoscale(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	opt.Autoscale = &AutoscaleOptions{
+		MinCap:          1,
+		MaxCap:          4,
+		TargetRefCount:  2,
+		Interval:        10 * time.Millisecond,
+		ScaleDownRounds: 2,
+	}
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	held := make([]*GrpcConn, 0, 8)
+	for i := 0; i < 8; i++ {
+		gConn, err := pool.Get()
+		assert.Nil(t, err)
+		held = append(held, gConn)
+	}
+	assert.Eventually(t, func() bool {
+		return pool.Cap() == 4 && pool.Len() == 4
+	}, time.Second, 5*time.Millisecond)
+
+	for _, gConn := range held {
+		gConn.Release()
+	}
+	assert.Eventually(t, func() bool {
+		return pool.Cap() == 1 && pool.Len() == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestGRPCPool_AutoscaleServesQueued(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	opt.MaxStreamsPerConn = 1
+	opt.Autoscale = &AutoscaleOptions{
+		MinCap:         1,
+		MaxCap:         2,
+		TargetRefCount: 1,
+		Interval:       10 * time.Millisecond,
+	}
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	held, err := pool.Get()
+	assert.Nil(t, err)
+	defer held.Release()
+
+	//a caller queued on the saturated pool makes it grow and is served by the new connection
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	gConn, err := pool.GetContext(ctx)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, 2, pool.Cap())
+	gConn.Release()
+}
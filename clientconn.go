@@ -0,0 +1,113 @@
+package pool
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+//clusterConn is a grpc.ClientConnInterface backed by the pool of a ServerCluster
+type clusterConn struct {
+	server *ServerCluster
+}
+
+//ClientConn return a grpc.ClientConnInterface backed by the cluster pool
+//each call acquires a connection and releases it when the unary call returns or the stream ends ,
+//so clients built on it can be created once and reused without release
+//a stream ends when it returns an error or io.EOF , or when its ctx is done
+func (server *ServerCluster) ClientConn() grpc.ClientConnInterface {
+	return &clusterConn{server: server}
+}
+
+//Invoke perform a unary call on a pooled connection
+//the connection acquisition is bounded by ctx
+func (cc *clusterConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	lease, err := cc.server.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+	return lease.Conn().Invoke(ctx, method, args, reply, opts...)
+}
+
+//NewStream begin a stream on a pooled connection which is released when the stream ends
+//the end is watched on a ctx derived here instead of stream.Context() , so grpc can still retry the stream
+func (cc *clusterConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	lease, err := cc.server.Acquire(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stream, err := lease.Conn().NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		lease.Release()
+		cancel()
+		return nil, err
+	}
+	s := &pooledStream{ClientStream: stream, release: func() {
+		lease.Release()
+		cancel()
+	}}
+	go s.watch(ctx.Done())
+	return s, nil
+}
+
+//ReleaseOnStreamEnd call release exactly once when stream ends and return the wrapped stream
+//a stream ends when RecvMsg returns an error or io.EOF , or when its context is done
+//use it for streams created on a connection from GetClient or GetServerClient instead of calling release yourself :
+//
+//	stream, err := client.Watch(ctx, req)
+//	if err != nil {
+//		release()
+//		return err
+//	}
+//	ReleaseOnStreamEnd(stream, release)
+//
+//RecvMsg of the returned stream releases at once , the typed stream is released after its context is done
+//a stream never read until its end must be canceled by ctx , otherwise it is never released
+//it watches stream.Context() , which commits the stream , so grpc does not retry it transparently any more ,
+//streams of ServerCluster.ClientConn do not pay it
+func ReleaseOnStreamEnd(stream grpc.ClientStream, release func()) grpc.ClientStream {
+	s := &pooledStream{ClientStream: stream, release: release}
+	//the stream context is canceled by grpc once the stream is finished for any reason
+	go s.watch(stream.Context().Done())
+	return s
+}
+
+//pooledStream is a grpc.ClientStream holding a pooled connection until it ends
+type pooledStream struct {
+	grpc.ClientStream
+	release func()
+	once    sync.Once
+}
+
+//RecvMsg receive a message and release the connection when the stream ends
+func (s *pooledStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.end()
+	}
+	return err
+}
+
+//CloseSend close the send direction and release the connection when it fails
+func (s *pooledStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.end()
+	}
+	return err
+}
+
+//watch release the connection once done is closed
+func (s *pooledStream) watch(done <-chan struct{}) {
+	<-done
+	s.end()
+}
+
+//end call release only once
+func (s *pooledStream) end() {
+	s.once.Do(s.release)
+}
@@ -0,0 +1,193 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+//testingStreamDesc is a bidi streaming service answering each request with SERVING
+//a request for service "error" ends the stream with Unavailable
+var testingStreamDesc = grpc.ServiceDesc{
+	ServiceName: "grpcpool.testing.Stream",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Watch",
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			for {
+				req := new(healthpb.HealthCheckRequest)
+				err := stream.RecvMsg(req)
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if req.Service == "error" {
+					return status.Error(codes.Unavailable, "testing error")
+				}
+				if err := stream.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+					return err
+				}
+			}
+		},
+	}},
+}
+
+const testingStreamMethod = "/grpcpool.testing.Stream/Watch"
+
+//startStreamServer run an in-process grpc server with the testing stream service
+func startStreamServer(t testing.TB) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	server.RegisterService(&testingStreamDesc, struct{}{})
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	return lis.Addr().String(), server.Stop
+}
+
+//waitRefCount wait until the refcount of conn is n or timeout
+func waitRefCount(conn *GrpcConn, n int64) bool {
+	for i := 0; i < 100; i++ {
+		if conn.RefCount() == n {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestServerCluster_ClientConn(t *testing.T) {
+	addr, healthServer, stop := startHealthServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	opt.MaxStreamsPerConn = 1
+	server, err := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	assert.Nil(t, err)
+	defer server.Pool.Close()
+
+	//the client is built once and reused
+	client := healthpb.NewHealthClient(server.ClientConn())
+	conn, err := server.GetClient()
+	assert.Nil(t, err)
+	conn.Release()
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+		assert.EqualValues(t, 0, conn.RefCount())
+	}
+
+	//a stream holds its connection until it ends
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	resp, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	assert.EqualValues(t, 1, conn.RefCount())
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	_, err = stream.Recv()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, conn.RefCount())
+	cancel()
+	assert.True(t, waitRefCount(conn, 0))
+
+	//acquisition is bounded by ctx
+	conn, err = server.GetClient()
+	assert.Nil(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	conn.Release()
+}
+
+func TestReleaseOnStreamEnd(t *testing.T) {
+	addr, stop := startStreamServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	server, err := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	assert.Nil(t, err)
+	defer server.Pool.Close()
+
+	newStream := func(ctx context.Context) (*GrpcConn, grpc.ClientStream) {
+		conn, err := server.GetClient()
+		assert.Nil(t, err)
+		stream, err := conn.Conn().NewStream(ctx, &testingStreamDesc.Streams[0], testingStreamMethod)
+		assert.Nil(t, err)
+		return conn, ReleaseOnStreamEnd(stream, conn.Release)
+	}
+	send := func(stream grpc.ClientStream, service string) error {
+		assert.Nil(t, stream.SendMsg(&healthpb.HealthCheckRequest{Service: service}))
+		return stream.RecvMsg(new(healthpb.HealthCheckResponse))
+	}
+
+	//released at EOF
+	conn, stream := newStream(context.Background())
+	assert.Nil(t, send(stream, ""))
+	assert.Nil(t, send(stream, ""))
+	assert.EqualValues(t, 1, conn.RefCount())
+	assert.Nil(t, stream.CloseSend())
+	assert.Equal(t, io.EOF, stream.RecvMsg(new(healthpb.HealthCheckResponse)))
+	assert.EqualValues(t, 0, conn.RefCount())
+	//released only once
+	assert.Equal(t, io.EOF, stream.RecvMsg(new(healthpb.HealthCheckResponse)))
+	time.Sleep(10 * time.Millisecond)
+	assert.EqualValues(t, 0, conn.RefCount())
+
+	//released at error
+	conn, stream = newStream(context.Background())
+	assert.Nil(t, send(stream, ""))
+	err = send(stream, "error")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.EqualValues(t, 0, conn.RefCount())
+	time.Sleep(10 * time.Millisecond)
+	assert.EqualValues(t, 0, conn.RefCount())
+
+	//released at context cancellation without reading
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, stream = newStream(ctx)
+	assert.Nil(t, send(stream, ""))
+	assert.EqualValues(t, 1, conn.RefCount())
+	cancel()
+	assert.True(t, waitRefCount(conn, 0))
+	assert.Equal(t, codes.Canceled, status.Code(stream.RecvMsg(new(healthpb.HealthCheckResponse))))
+	time.Sleep(10 * time.Millisecond)
+	assert.EqualValues(t, 0, conn.RefCount())
+
+	//streams of ClientConn are released the same way
+	cs, err := server.ClientConn().NewStream(context.Background(), &testingStreamDesc.Streams[0], testingStreamMethod)
+	assert.Nil(t, err)
+	assert.Nil(t, send(cs, ""))
+	assert.EqualValues(t, 1, conn.RefCount())
+	assert.Nil(t, cs.CloseSend())
+	assert.Equal(t, io.EOF, cs.RecvMsg(new(healthpb.HealthCheckResponse)))
+	assert.EqualValues(t, 0, conn.RefCount())
+	//and at cancellation of the ctx given to NewStream
+	ctx, cancel = context.WithCancel(context.Background())
+	cs, err = server.ClientConn().NewStream(ctx, &testingStreamDesc.Streams[0], testingStreamMethod)
+	assert.Nil(t, err)
+	assert.Nil(t, send(cs, ""))
+	assert.EqualValues(t, 1, conn.RefCount())
+	cancel()
+	assert.True(t, waitRefCount(conn, 0))
+	assert.Equal(t, codes.Canceled, status.Code(cs.RecvMsg(new(healthpb.HealthCheckResponse))))
+	time.Sleep(10 * time.Millisecond)
+	assert.EqualValues(t, 0, conn.RefCount())
+}
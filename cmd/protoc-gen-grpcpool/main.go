@@ -0,0 +1,100 @@
+//protoc-gen-grpcpool generates typed grpcpool client builders for proto services
+//
+//	protoc --go_out=. --go-grpc_out=. --grpcpool_out=. helloworld.proto
+//
+//for each service Foo it generates in foo_grpcpool.pb.go :
+//FooBuilderName , NewFooBuilder , RegisterFooBuilder , GetFooClient and GetFooClientContext
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const (
+	contextPackage = protogen.GoImportPath("context")
+	poolPackage    = protogen.GoImportPath("github.com/kasiss-liu/grpcpool")
+)
+
+func main() {
+	protogen.Options{}.Run(generate)
+}
+
+//generate generate the builders of every file to generate
+//proto3 optional fields are supported , they do not change services
+func generate(gen *protogen.Plugin) error {
+	gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+	for _, f := range gen.Files {
+		if f.Generate {
+			generateFile(gen, f)
+		}
+	}
+	return nil
+}
+
+//generateFile generate the builders of services in file , nothing is generated without services
+func generateFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	if len(file.Services) == 0 {
+		return nil
+	}
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_grpcpool.pb.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-grpcpool. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+	for _, service := range file.Services {
+		generateService(g, service)
+	}
+	return g
+}
+
+//generateService generate the builders of service
+func generateService(g *protogen.GeneratedFile, service *protogen.Service) {
+	name := service.GoName
+	client := name + "Client"
+	builderName := name + "BuilderName"
+	serverBuilderFunc := g.QualifiedGoIdent(poolPackage.Ident("ServerBuilderFunc"))
+	serverCluster := g.QualifiedGoIdent(poolPackage.Ident("ServerCluster"))
+	serviceCenter := g.QualifiedGoIdent(poolPackage.Ident("ServiceCenter"))
+	errClusterNotFound := g.QualifiedGoIdent(poolPackage.Ident("ErrClusterNotFound"))
+
+	g.P("// ", builderName, " is the name of ", client, " builder in ServerCluster.")
+	g.P("const ", builderName, " = ", `"`, service.Desc.FullName(), `"`)
+	g.P()
+
+	g.P("// New", name, "Builder returns the typed builder of ", client, ".")
+	g.P("func New", name, "Builder() ", serverBuilderFunc, " {")
+	g.P("return ", poolPackage.Ident("NewServerBuilder"), "(New", client, ")")
+	g.P("}")
+	g.P()
+
+	g.P("// Register", name, "Builder sets the ", client, " builder on cluster.")
+	g.P("func Register", name, "Builder(cluster *", serverCluster, ") {")
+	g.P("cluster.SetClientBuilders(map[string]", serverBuilderFunc, "{")
+	g.P(builderName, ": New", name, "Builder(),")
+	g.P("})")
+	g.P("}")
+	g.P()
+
+	g.P("// Get", client, " returns a ", client, " of the named cluster in sc.")
+	g.P("// release must be called after the calls are done.")
+	g.P("func Get", client, "(sc *", serviceCenter, ", cluster string) (", client, ", func(), error) {")
+	g.P("server, ok := sc.Get(cluster)")
+	g.P("if !ok {")
+	g.P("return nil, nil, ", errClusterNotFound)
+	g.P("}")
+	g.P("return ", poolPackage.Ident("GetClient"), "[", client, "](server, ", builderName, ")")
+	g.P("}")
+	g.P()
+
+	g.P("// Get", client, "Context is like Get", client, " but waits for a connection until ctx is done.")
+	g.P("func Get", client, "Context(ctx ", contextPackage.Ident("Context"), ", sc *", serviceCenter, ", cluster string) (", client, ", func(), error) {")
+	g.P("server, ok := sc.Get(cluster)")
+	g.P("if !ok {")
+	g.P("return nil, nil, ", errClusterNotFound)
+	g.P("}")
+	g.P("return ", poolPackage.Ident("GetClientContext"), "[", client, "](ctx, server, ", builderName, ")")
+	g.P("}")
+	g.P()
+}
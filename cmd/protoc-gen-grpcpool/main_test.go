@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var update = flag.Bool("update", false, "update the generated file of helloworld example")
+
+const helloworldGolden = "../../_examples/helloworld/protos/helloworld/helloworld_grpcpool.pb.go"
+
+//helloworldRequest return the request of _examples/helloworld/protos/helloworld/helloworld.proto
+func helloworldRequest() *pluginpb.CodeGeneratorRequest {
+	message := func(name, field string) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String(field),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				JsonName: proto.String(field),
+			}},
+		}
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("helloworld.proto"),
+		Package: proto.String("helloworld"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("github.com/kasiss-liu/grpcpool/_examples/helloworld/protos/helloworld"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("HelloRequest", "Name"),
+			message("HelloResp", "Words"),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("HelloWorld"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("SayHello"),
+				InputType:  proto.String(".helloworld.HelloRequest"),
+				OutputType: proto.String(".helloworld.HelloResp"),
+			}},
+		}},
+	}
+	return &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"helloworld.proto"},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	}
+}
+
+func TestGenerateFile(t *testing.T) {
+	gen, err := protogen.Options{}.New(helloworldRequest())
+	assert.Nil(t, err)
+	g := generateFile(gen, gen.Files[0])
+	assert.NotNil(t, g)
+
+	resp := gen.Response()
+	assert.Nil(t, resp.Error)
+	assert.Len(t, resp.File, 1)
+	assert.Equal(t, "github.com/kasiss-liu/grpcpool/_examples/helloworld/protos/helloworld/helloworld_grpcpool.pb.go", resp.File[0].GetName())
+
+	content := resp.File[0].GetContent()
+	if *update {
+		assert.Nil(t, ioutil.WriteFile(helloworldGolden, []byte(content), 0644))
+	}
+	golden, err := ioutil.ReadFile(helloworldGolden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(golden), content)
+}
+
+func TestGenerateProto3Optional(t *testing.T) {
+	req := helloworldRequest()
+	//optional string Nick = 2 , in the synthetic oneof _Nick
+	request := req.ProtoFile[0].MessageType[0]
+	request.Field = append(request.Field, &descriptorpb.FieldDescriptorProto{
+		Name:           proto.String("Nick"),
+		Number:         proto.Int32(2),
+		Label:          descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:           descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		JsonName:       proto.String("Nick"),
+		OneofIndex:     proto.Int32(0),
+		Proto3Optional: proto.Bool(true),
+	})
+	request.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_Nick")}}
+
+	gen, err := protogen.Options{}.New(req)
+	assert.Nil(t, err)
+	assert.Nil(t, generate(gen))
+
+	resp := gen.Response()
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL), resp.GetSupportedFeatures())
+	if assert.Len(t, resp.File, 1) {
+		golden, err := ioutil.ReadFile(helloworldGolden)
+		assert.Nil(t, err)
+		assert.Equal(t, string(golden), resp.File[0].GetContent())
+	}
+}
+
+func TestGenerateFileWithoutService(t *testing.T) {
+	req := helloworldRequest()
+	req.ProtoFile[0].Service = nil
+	gen, err := protogen.Options{}.New(req)
+	assert.Nil(t, err)
+	assert.Nil(t, generateFile(gen, gen.Files[0]))
+	assert.Len(t, gen.Response().File, 0)
+}
@@ -0,0 +1,526 @@
+package pool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/yaml.v3"
+)
+
+//builders are ServerBuilderFunc registered by name for configuration
+var builders = struct {
+	sync.RWMutex
+	m map[string]ServerBuilderFunc
+}{m: make(map[string]ServerBuilderFunc)}
+
+//RegisterServerBuilder register fn by name , configurations refer to it in services
+//registering a name again replaces the previous one
+func RegisterServerBuilder(name string, fn ServerBuilderFunc) {
+	builders.Lock()
+	defer builders.Unlock()
+	builders.m[name] = fn
+}
+
+//lookupServerBuilder return the ServerBuilderFunc registered by name
+func lookupServerBuilder(name string) (ServerBuilderFunc, bool) {
+	builders.RLock()
+	defer builders.RUnlock()
+	fn, ok := builders.m[name]
+	return fn, ok
+}
+
+//pickers are the Picker names of configuration
+var pickers = map[string]func() Picker{
+	"round_robin":    NewRoundRobinPicker,
+	"random":         NewRandomPicker,
+	"least_refcount": NewLeastRefCountPicker,
+	"p2c":            NewP2CPicker,
+}
+
+//ConfigError is an error of a field in ServiceCenter configuration
+//Path is the field path like clusters.server1.targets[0]
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+//Error return the message with field path
+func (e *ConfigError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+//Unwrap return the underlying error
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+//configErr return a *ConfigError of path with message
+func configErr(path string, msg string) error {
+	return &ConfigError{Path: path, Err: errors.New(msg)}
+}
+
+//Duration is a time.Duration written as a string like "5s" in configuration
+type Duration time.Duration
+
+//UnmarshalYAML parse the duration string
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("line " + strconv.Itoa(node.Line) + ": invalid duration " + strconv.Quote(s))
+	}
+	*d = Duration(v)
+	return nil
+}
+
+//ServiceCenterConfig is the declarative configuration of a ServiceCenter
+//it is written in YAML or JSON , see ParseServiceCenterConfig
+type ServiceCenterConfig struct {
+	//Defaults are the settings every cluster starts with
+	Defaults ClusterConfig `yaml:"defaults"`
+	//Clusters are the settings of clusters by name , merged over Defaults
+	Clusters map[string]ClusterConfig `yaml:"clusters"`
+}
+
+//ClusterConfig is the configuration of one ServerCluster
+type ClusterConfig struct {
+	Targets           []string `yaml:"targets"`
+	Cap               int      `yaml:"cap"`
+	DialTimeout       Duration `yaml:"dial_timeout"`
+	MaxStreamsPerConn int      `yaml:"max_streams_per_conn"`
+	//Picker is one of round_robin , random , least_refcount and p2c , it chooses among connections of all targets
+	//empty means the default Picker which follows target weights
+	Picker          string            `yaml:"picker"`
+	HealthCheck     HealthCheckConfig `yaml:"health_check"`
+	MaxIdleTime     Duration          `yaml:"max_idle_time"`
+	MaxConnLifetime Duration          `yaml:"max_conn_lifetime"`
+	MinIdle         int               `yaml:"min_idle"`
+	Autoscale       *AutoscaleConfig  `yaml:"autoscale"`
+	Keepalive       KeepaliveConfig   `yaml:"keepalive"`
+	//TLS enables transport security , the connections are insecure when it is null
+	TLS *TLSConfig `yaml:"tls"`
+	//Services map service names to names of builders registered by RegisterServerBuilder
+	Services map[string]string `yaml:"services"`
+}
+
+//HealthCheckConfig is the health check settings of a cluster
+type HealthCheckConfig struct {
+	Interval Duration `yaml:"interval"`
+	Timeout  Duration `yaml:"timeout"`
+	Service  string   `yaml:"service"`
+}
+
+//AutoscaleConfig is the autoscale settings of a cluster
+type AutoscaleConfig struct {
+	MinCap          int      `yaml:"min_cap"`
+	MaxCap          int      `yaml:"max_cap"`
+	TargetRefCount  float64  `yaml:"target_ref_count"`
+	Tolerance       float64  `yaml:"tolerance"`
+	Interval        Duration `yaml:"interval"`
+	ScaleDownRounds int      `yaml:"scale_down_rounds"`
+}
+
+//KeepaliveConfig is the client keepalive settings of a cluster
+type KeepaliveConfig struct {
+	Enabled             bool     `yaml:"enabled"`
+	Time                Duration `yaml:"time"`
+	Timeout             Duration `yaml:"timeout"`
+	PermitWithoutStream bool     `yaml:"permit_without_stream"`
+}
+
+//TLSConfig is the transport security settings of a cluster
+type TLSConfig struct {
+	//CAFile is the PEM file of root certificates , system roots are used when it is empty
+	CAFile string `yaml:"ca_file"`
+	//CertFile and KeyFile are the client certificate , both or neither are set
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+//defaultClusterConfig is the settings before defaults in configuration , same as NewOptions
+func defaultClusterConfig() ClusterConfig {
+	return ClusterConfig{
+		DialTimeout: Duration(5 * time.Second),
+		Keepalive: KeepaliveConfig{
+			Enabled: true,
+			Time:    Duration(5 * time.Second),
+			Timeout: Duration(5 * time.Second),
+		},
+	}
+}
+
+//clone return a deep copy of c
+func (c ClusterConfig) clone() ClusterConfig {
+	c.Targets = append([]string(nil), c.Targets...)
+	if c.Autoscale != nil {
+		autoscale := *c.Autoscale
+		c.Autoscale = &autoscale
+	}
+	if c.TLS != nil {
+		tlsConfig := *c.TLS
+		c.TLS = &tlsConfig
+	}
+	if c.Services != nil {
+		services := make(map[string]string, len(c.Services))
+		for name, builder := range c.Services {
+			services[name] = builder
+		}
+		c.Services = services
+	}
+	return c
+}
+
+//ParseServiceCenterConfig read and validate a YAML or JSON configuration
+//errors of fields are *ConfigError with the field path
+func ParseServiceCenterConfig(r io.Reader) (*ServiceCenterConfig, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	cfg := &ServiceCenterConfig{Defaults: defaultClusterConfig()}
+	if len(doc.Content) == 0 {
+		return cfg, nil
+	}
+	root := doc.Content[0]
+	if err := checkFields(root, reflect.TypeOf(cfg).Elem(), ""); err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Defaults yaml.Node            `yaml:"defaults"`
+		Clusters map[string]yaml.Node `yaml:"clusters"`
+	}
+	if err := root.Decode(&raw); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	if raw.Defaults.Kind != 0 {
+		if err := raw.Defaults.Decode(&cfg.Defaults); err != nil {
+			return nil, &ConfigError{Path: "defaults", Err: err}
+		}
+	}
+	cfg.Clusters = make(map[string]ClusterConfig, len(raw.Clusters))
+	for _, name := range sortedKeys(raw.Clusters) {
+		path := "clusters." + name
+		node := raw.Clusters[name]
+		c := cfg.Defaults.clone()
+		if err := node.Decode(&c); err != nil {
+			return nil, &ConfigError{Path: path, Err: err}
+		}
+		if err := c.validate(path); err != nil {
+			return nil, err
+		}
+		cfg.Clusters[name] = c
+	}
+	return cfg, nil
+}
+
+//sortedKeys return keys of m in order
+func sortedKeys(m map[string]yaml.Node) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//checkFields report the first field of node unknown to t or not decoded as its type
+func checkFields(node *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			field, ok := fieldByTag(t, key)
+			if !ok {
+				return configErr(joinPath(path, key), "unknown field")
+			}
+			if err := checkFields(node.Content[i+1], field.Type, joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := checkFields(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			if err := checkFields(item, t.Elem(), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			//keep the first message only , yaml joins them with the header
+			if te, ok := err.(*yaml.TypeError); ok && len(te.Errors) > 0 {
+				err = errors.New(te.Errors[0])
+			}
+			return &ConfigError{Path: path, Err: err}
+		}
+	}
+	return nil
+}
+
+//fieldByTag return the field of struct t with yaml name
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Split(field.Tag.Get("yaml"), ",")[0] == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+//joinPath return the path of field name under path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+//validate check c and return *ConfigError of the first invalid field under path
+func (c ClusterConfig) validate(path string) error {
+	if len(c.Targets) == 0 {
+		return configErr(path+".targets", "must not be empty")
+	}
+	for i, target := range c.Targets {
+		if _, err := ParseTarget(target); err != nil {
+			return &ConfigError{Path: path + ".targets[" + strconv.Itoa(i) + "]", Err: err}
+		}
+	}
+	if c.Cap <= 0 {
+		return configErr(path+".cap", "must be greater than 0")
+	}
+	if c.DialTimeout <= 0 {
+		return configErr(path+".dial_timeout", "must be greater than 0")
+	}
+	for _, f := range []struct {
+		name  string
+		value int64
+	}{
+		{"max_streams_per_conn", int64(c.MaxStreamsPerConn)},
+		{"health_check.interval", int64(c.HealthCheck.Interval)},
+		{"health_check.timeout", int64(c.HealthCheck.Timeout)},
+		{"max_idle_time", int64(c.MaxIdleTime)},
+		{"max_conn_lifetime", int64(c.MaxConnLifetime)},
+		{"min_idle", int64(c.MinIdle)},
+	} {
+		if f.value < 0 {
+			return configErr(path+"."+f.name, "must not be negative")
+		}
+	}
+	if c.MinIdle > c.Cap {
+		return configErr(path+".min_idle", "must not be greater than cap")
+	}
+	if _, ok := pickers[c.Picker]; c.Picker != "" && !ok {
+		return configErr(path+".picker", "unknown picker "+strconv.Quote(c.Picker)+
+			" , must be one of round_robin , random , least_refcount and p2c")
+	}
+	if c.Keepalive.Enabled {
+		if c.Keepalive.Time <= 0 {
+			return configErr(path+".keepalive.time", "must be greater than 0")
+		}
+		if c.Keepalive.Timeout <= 0 {
+			return configErr(path+".keepalive.timeout", "must be greater than 0")
+		}
+	}
+	if a := c.Autoscale; a != nil {
+		switch {
+		case a.MinCap <= 0:
+			return configErr(path+".autoscale.min_cap", "must be greater than 0")
+		case a.MaxCap < a.MinCap:
+			return configErr(path+".autoscale.max_cap", "must not be less than min_cap")
+		case a.TargetRefCount <= 0:
+			return configErr(path+".autoscale.target_ref_count", "must be greater than 0")
+		case a.Tolerance < 0 || a.Tolerance >= 1:
+			return configErr(path+".autoscale.tolerance", "must be in [0, 1)")
+		case a.Interval <= 0:
+			return configErr(path+".autoscale.interval", "must be greater than 0")
+		case a.ScaleDownRounds < 0:
+			return configErr(path+".autoscale.scale_down_rounds", "must not be negative")
+		case c.Cap < a.MinCap || c.Cap > a.MaxCap:
+			return configErr(path+".cap", "must be between autoscale.min_cap and autoscale.max_cap")
+		}
+	}
+	if c.TLS != nil && (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return configErr(path+".tls", "cert_file and key_file must be set together")
+	}
+	for _, name := range sortedServices(c.Services) {
+		if _, ok := lookupServerBuilder(c.Services[name]); !ok {
+			return configErr(path+".services."+name, "builder "+strconv.Quote(c.Services[name])+" is not registered")
+		}
+	}
+	return nil
+}
+
+//sortedServices return service names of services in order
+func sortedServices(services map[string]string) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//options return pool Options of c
+func (c ClusterConfig) options() Options {
+	opt := Options{
+		Cap:                 c.Cap,
+		Targets:             append([]string(nil), c.Targets...),
+		ClientKeepAlive:     c.Keepalive.Enabled,
+		DialTimeout:         time.Duration(c.DialTimeout),
+		IdleTimeout:         time.Duration(c.Keepalive.Time),
+		PingTimeout:         time.Duration(c.Keepalive.Timeout),
+		ForcePermit:         c.Keepalive.PermitWithoutStream,
+		MaxStreamsPerConn:   c.MaxStreamsPerConn,
+		HealthCheckInterval: time.Duration(c.HealthCheck.Interval),
+		HealthCheckTimeout:  time.Duration(c.HealthCheck.Timeout),
+		HealthCheckService:  c.HealthCheck.Service,
+		MaxIdleTime:         time.Duration(c.MaxIdleTime),
+		MaxConnLifetime:     time.Duration(c.MaxConnLifetime),
+		MinIdle:             c.MinIdle,
+	}
+	if newPicker, ok := pickers[c.Picker]; ok {
+		opt.Picker = newPicker()
+	}
+	if a := c.Autoscale; a != nil {
+		opt.Autoscale = &AutoscaleOptions{
+			MinCap:          a.MinCap,
+			MaxCap:          a.MaxCap,
+			TargetRefCount:  a.TargetRefCount,
+			Tolerance:       a.Tolerance,
+			Interval:        time.Duration(a.Interval),
+			ScaleDownRounds: a.ScaleDownRounds,
+		}
+	}
+	return opt
+}
+
+//dialOptions return grpc dial options of c , TLS files are read here
+func (c ClusterConfig) dialOptions(path string) ([]grpc.DialOption, error) {
+	if c.TLS == nil {
+		return []grpc.DialOption{grpc.WithInsecure()}, nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         c.TLS.ServerName,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+	}
+	if c.TLS.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.TLS.CAFile)
+		if err != nil {
+			return nil, &ConfigError{Path: path + ".tls.ca_file", Err: err}
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, configErr(path+".tls.ca_file", "no certificate found")
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if c.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+		if err != nil {
+			return nil, &ConfigError{Path: path + ".tls.cert_file", Err: err}
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}, nil
+}
+
+//serverBuilders return the builders of services of c
+func (c ClusterConfig) serverBuilders() map[string]ServerBuilderFunc {
+	fns := make(map[string]ServerBuilderFunc, len(c.Services))
+	for name, builder := range c.Services {
+		fns[name], _ = lookupServerBuilder(builder)
+	}
+	return fns
+}
+
+//newServerCluster return a *ServerCluster of c named name
+func (c ClusterConfig) newServerCluster(name string) (*ServerCluster, error) {
+	path := "clusters." + name
+	dialOptions, err := c.dialOptions(path)
+	if err != nil {
+		return nil, err
+	}
+	server, err := NewServerClusterWithBuilders(name, c.options(), dialOptions, c.serverBuilders())
+	if err != nil {
+		return nil, &ConfigError{Path: path, Err: err}
+	}
+	server.config = &c
+	return server, nil
+}
+
+//NewServiceCenterFromConfig return a *ServiceCenter with clusters of cfg
+//pools already created are closed when any cluster fails
+func NewServiceCenterFromConfig(cfg *ServiceCenterConfig) (*ServiceCenter, error) {
+	sc := &ServiceCenter{}
+	names := make([]string, 0, len(cfg.Clusters))
+	for name := range cfg.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	created := make([]*ServerCluster, 0, len(names))
+	for _, name := range names {
+		server, err := cfg.Clusters[name].newServerCluster(name)
+		if err != nil {
+			for _, s := range created {
+				s.Pool.Close()
+			}
+			return nil, err
+		}
+		created = append(created, server)
+		sc.Register(server)
+	}
+	return sc, nil
+}
+
+//LoadServiceCenterConfig return a *ServiceCenter built from a YAML or JSON configuration
+//
+//	defaults:
+//	  cap: 10
+//	  dial_timeout: 3s
+//	  keepalive: {enabled: true, time: 10s, timeout: 5s}
+//	clusters:
+//	  server1:
+//	    targets: ["10.0.0.1:9999", "10.0.0.2:9999;weight=2"]
+//	    tls: {ca_file: /etc/ca.pem, server_name: server1}
+//	    services: {demoService: demo}
+//
+//errors of fields are *ConfigError with the field path , see ParseServiceCenterConfig
+func LoadServiceCenterConfig(r io.Reader) (*ServiceCenter, error) {
+	cfg, err := ParseServiceCenterConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewServiceCenterFromConfig(cfg)
+}
@@ -0,0 +1,160 @@
+package pool
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	RegisterServerBuilder("testing", clientBuilder)
+}
+
+const testingConfig = `
+defaults:
+  cap: 4
+  dial_timeout: 3s
+  keepalive:
+    time: 10s
+  services:
+    default: testing
+clusters:
+  server1:
+    targets: ["127.0.0.1:9999", "127.0.0.1:9998;weight=2"]
+    picker: p2c
+    max_streams_per_conn: 8
+    health_check: {interval: 1s, service: demo}
+  server2:
+    targets: ["127.0.0.1:9997"]
+    cap: 6
+    min_idle: 2
+    keepalive: {enabled: false}
+    autoscale: {min_cap: 2, max_cap: 8, target_ref_count: 4, interval: 1s}
+    tls: {insecure_skip_verify: true, server_name: server2}
+    services:
+      other: testing
+`
+
+func TestParseServiceCenterConfig(t *testing.T) {
+	cfg, err := ParseServiceCenterConfig(strings.NewReader(testingConfig))
+	assert.Nil(t, err)
+	assert.Len(t, cfg.Clusters, 2)
+
+	opt := cfg.Clusters["server1"].options()
+	assert.Equal(t, 4, opt.Cap)
+	assert.Equal(t, []string{"127.0.0.1:9999", "127.0.0.1:9998;weight=2"}, opt.Targets)
+	assert.Equal(t, 3*time.Second, opt.DialTimeout)
+	assert.True(t, opt.ClientKeepAlive)
+	assert.Equal(t, 10*time.Second, opt.IdleTimeout)
+	assert.Equal(t, 5*time.Second, opt.PingTimeout)
+	assert.Equal(t, 8, opt.MaxStreamsPerConn)
+	assert.Equal(t, time.Second, opt.HealthCheckInterval)
+	assert.Equal(t, "demo", opt.HealthCheckService)
+	assert.NotNil(t, opt.Picker)
+	assert.Nil(t, opt.Autoscale)
+	assert.Nil(t, cfg.Clusters["server1"].TLS)
+	assert.Equal(t, map[string]string{"default": "testing"}, cfg.Clusters["server1"].Services)
+
+	opt = cfg.Clusters["server2"].options()
+	assert.Equal(t, 6, opt.Cap)
+	assert.Equal(t, 2, opt.MinIdle)
+	assert.False(t, opt.ClientKeepAlive)
+	assert.Nil(t, opt.Picker)
+	assert.Equal(t, AutoscaleOptions{MinCap: 2, MaxCap: 8, TargetRefCount: 4, Interval: time.Second}, *opt.Autoscale)
+	assert.Equal(t, &TLSConfig{InsecureSkipVerify: true, ServerName: "server2"}, cfg.Clusters["server2"].TLS)
+	//services are merged over defaults without changing them
+	assert.Equal(t, map[string]string{"default": "testing", "other": "testing"}, cfg.Clusters["server2"].Services)
+	assert.Equal(t, map[string]string{"default": "testing"}, cfg.Defaults.Services)
+}
+
+func TestParseServiceCenterConfigJSON(t *testing.T) {
+	cfg, err := ParseServiceCenterConfig(strings.NewReader(`{
+		"defaults": {"cap": 2},
+		"clusters": {"server1": {"targets": ["127.0.0.1:9999"], "dial_timeout": "1s"}}
+	}`))
+	assert.Nil(t, err)
+	opt := cfg.Clusters["server1"].options()
+	assert.Equal(t, 2, opt.Cap)
+	assert.Equal(t, time.Second, opt.DialTimeout)
+	assert.Nil(t, opt.validate())
+}
+
+func TestParseServiceCenterConfigError(t *testing.T) {
+	for _, c := range []struct {
+		config string
+		msg    string
+	}{
+		{"clusters: {s1: {targets: [a:1], cap: 1, timeout: 1s}}", "clusters.s1.timeout: unknown field"},
+		{"defaults: {keepalive: {period: 1s}}", "defaults.keepalive.period: unknown field"},
+		{"clusters: {s1: {targets: [a:1]}}", "clusters.s1.cap: must be greater than 0"},
+		{"clusters: {s1: {cap: 1}}", "clusters.s1.targets: must not be empty"},
+		{"clusters: {s1: {cap: 1, targets: [a:1, 'b:1;weight=x']}}", "clusters.s1.targets[1]: target is invalid"},
+		{"clusters: {s1: {cap: 1, targets: [a:1], dial_timeout: 1}}", "clusters.s1.dial_timeout: line 1: invalid duration \"1\""},
+		{"clusters: {s1: {cap: 1, targets: [a:1], dial_timeout: 5x}}", "clusters.s1.dial_timeout: line 1: invalid duration \"5x\""},
+		{"defaults: {health_check: {interval: 5x}}", "defaults.health_check.interval: line 1: invalid duration \"5x\""},
+		{"clusters: {s1: {cap: abc, targets: [a:1]}}", "clusters.s1.cap: line 1: cannot unmarshal !!str `abc` into int"},
+		{"clusters: {s1: {cap: 1, targets: [a:1, [b:1]]}}", "clusters.s1.targets[1]: line 1: cannot unmarshal !!seq into string"},
+		{"clusters: {s1: {cap: 1, targets: [a:1], keepalive: 5}}", "clusters.s1.keepalive: line 1: cannot unmarshal !!int `5` into"},
+		{"clusters: {s1: {cap: 1, targets: [a:1], max_idle_time: -1s}}", "clusters.s1.max_idle_time: must not be negative"},
+		{"clusters: {s1: {cap: 1, targets: [a:1], min_idle: 2}}", "clusters.s1.min_idle: must not be greater than cap"},
+		{"clusters: {s1: {cap: 1, targets: [a:1], picker: fastest}}", "clusters.s1.picker: unknown picker \"fastest\""},
+		{"clusters: {s1: {cap: 1, targets: [a:1], keepalive: {timeout: 0s}}}", "clusters.s1.keepalive.timeout: must be greater than 0"},
+		{"clusters: {s1: {cap: 1, targets: [a:1], autoscale: {min_cap: 2, max_cap: 1}}}", "clusters.s1.autoscale.max_cap: must not be less than min_cap"},
+		{"clusters: {s1: {cap: 9, targets: [a:1], autoscale: {min_cap: 1, max_cap: 8, target_ref_count: 1, interval: 1s}}}", "clusters.s1.cap: must be between"},
+		{"clusters: {s1: {cap: 1, targets: [a:1], tls: {cert_file: a.pem}}}", "clusters.s1.tls: cert_file and key_file must be set together"},
+		{"clusters: {s1: {cap: 1, targets: [a:1], services: {demo: missing}}}", "clusters.s1.services.demo: builder \"missing\" is not registered"},
+	} {
+		_, err := ParseServiceCenterConfig(strings.NewReader(c.config))
+		if assert.NotNil(t, err, c.config) {
+			assert.IsType(t, (*ConfigError)(nil), err)
+			assert.True(t, strings.HasPrefix(err.Error(), c.msg), err.Error())
+		}
+	}
+
+	_, err := ParseServiceCenterConfig(strings.NewReader("clusters: {s1: {cap: 1, targets: ['a:1;w=1']}}"))
+	assert.True(t, errors.Is(err, ErrTargetInvalid))
+	_, err = ParseServiceCenterConfig(strings.NewReader("clusters: ["))
+	assert.NotNil(t, err)
+
+	//malformed yaml returns an error instead of panic
+	for _, config := range []string{"0: [:!00 \xef", "clusters: {s1: [\x00"} {
+		_, err = ParseServiceCenterConfig(strings.NewReader(config))
+		assert.IsType(t, (*ConfigError)(nil), err, config)
+		_, err = LoadServiceCenterConfig(strings.NewReader(config))
+		assert.IsType(t, (*ConfigError)(nil), err, config)
+	}
+}
+
+func TestLoadServiceCenterConfig(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	sc, err := LoadServiceCenterConfig(strings.NewReader(`
+clusters:
+  server1:
+    cap: 2
+    targets: ["` + addr + `"]
+    services: {default: testing}
+`))
+	assert.Nil(t, err)
+	s1, ok := sc.Get("server1")
+	assert.True(t, ok)
+	defer s1.Pool.Close()
+
+	client, release, err := s1.GetServerClient("default")
+	assert.Nil(t, err)
+	assert.IsType(t, (*testingBuilder)(nil), client)
+	release()
+
+	_, err = LoadServiceCenterConfig(strings.NewReader(`
+clusters:
+  server1:
+    cap: 1
+    targets: ["` + addr + `"]
+    tls: {ca_file: /nonexistent/ca.pem}
+`))
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "clusters.server1.tls.ca_file: "), err.Error())
+}
@@ -0,0 +1,124 @@
+package pool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//replaceRetryDelay is the wait before rebalancing again after a replacement dial fails
+const replaceRetryDelay = time.Second
+
+//dialCall is a pending dial , it holds a place in pool cap until done
+type dialCall struct {
+	target string
+	done   chan struct{}
+	conn   *GrpcConn
+	err    error
+	//replaces is the connection evicted after the new one is put into pool , nil for growing
+	replaces *GrpcConn
+	//generation is the generation of pool settings the connection is dialed with
+	generation int
+}
+
+//startDial reserve a place for a new connection and dial it in background
+//the target with the fewest connections is dialed unless connFactory chooses target itself
+//must be called with p.lock held on an open pool
+func (p *GRPCPool) startDial() *dialCall {
+	return p.startReplace(nil)
+}
+
+//startReplace dial a connection for old in background , old is evicted only after the new one is put into pool
+//so Get keeps serving by old meanwhile , old is kept in pool when the dial fails
+//must be called with p.lock held on an open pool
+func (p *GRPCPool) startReplace(old *GrpcConn) *dialCall {
+	call := &dialCall{done: make(chan struct{}), replaces: old, generation: p.generation}
+	if !p.untargeted {
+		call.target = p.nextDialTarget()
+	}
+	p.dialing = append(p.dialing, call)
+	atomic.AddInt32(&p.pending, 1)
+	go p.dial(call, p.connFactory, p.connDoClose)
+	return call
+}
+
+//dial create a connection outside the lock and put it into pool
+func (p *GRPCPool) dial(call *dialCall, factory TargetConnFactoryFunc, doClose ConnCloseFunc) {
+	gconn, err := factory(p, call.target)
+
+	p.lock.Lock()
+	for i, c := range p.dialing {
+		if c == call {
+			copy(p.dialing[i:], p.dialing[i+1:])
+			p.dialing[len(p.dialing)-1] = nil
+			p.dialing = p.dialing[:len(p.dialing)-1]
+			atomic.AddInt32(&p.pending, -1)
+			break
+		}
+	}
+	closed := p.isClosed()
+	//the replaced conn may be evicted during dialing
+	replaced := call.replaces != nil && p.contains(call.replaces)
+	//pool may be shrunk during dialing
+	full := p.Len() >= p.Cap() && !replaced
+	//target may be removed during dialing
+	_, stale := p.loadTargets().index[call.target]
+	stale = call.target != "" && !stale
+	if err == nil && !closed && !full && !stale {
+		call.conn = p.wrapConn(gconn, call.target, call.generation)
+		p.addConn(call.conn)
+		if replaced {
+			p.evict(call.replaces)
+		}
+		//settings are replaced during dialing
+		if call.conn.generation != p.generation {
+			p.startReplace(call.conn)
+		}
+		//wake up callers queued on an exhausted pool to use the new conn
+		p.broadcast()
+	} else if replaced && !closed {
+		//the replaced conn keeps serving , try again later
+		time.AfterFunc(replaceRetryDelay, p.retryRebalance)
+	}
+	if err == nil && closed {
+		err = ErrPoolClosed
+	}
+	call.err = err
+	close(call.done)
+	p.lock.Unlock()
+
+	//new conn is not put into pool
+	if gconn != nil && call.conn == nil {
+		_ = doClose(gconn)
+	}
+}
+
+//replacing report whether a replacement of conn is dialing
+//must be called with p.lock held
+func (p *GRPCPool) replacing(conn *GrpcConn) bool {
+	for _, call := range p.dialing {
+		if call.replaces == conn {
+			return true
+		}
+	}
+	return false
+}
+
+//refill dial at most n connections in background without exceeding the cap
+//must be called with p.lock held
+func (p *GRPCPool) refill(n int) {
+	for ; n > 0 && p.Len()+len(p.dialing) < p.Cap(); n-- {
+		p.startDial()
+	}
+}
+
+//waitDials wait all calls done and return the first error
+func waitDials(calls []*dialCall) error {
+	var err error
+	for _, call := range calls {
+		<-call.done
+		if call.err != nil && err == nil {
+			err = call.err
+		}
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+//createSlowDialPool return a pool whose first dial is fast
+//and later dials hang until unblock is closed
+func createSlowDialPool(t testing.TB, cap int) (*GRPCPool, chan struct{}, *int32) {
+	addr, stop := startTestingServer(t)
+	opt, _ := NewOptions(cap, []string{addr})
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	unblock := make(chan struct{})
+	dials := new(int32)
+	pool.SetConnFactory(func(p *GRPCPool) (*grpc.ClientConn, error) {
+		if atomic.AddInt32(dials, 1) > 1 {
+			<-unblock
+		}
+		return grpc.Dial(addr, grpc.WithInsecure())
+	})
+	go func() {
+		<-pool.done
+		stop()
+	}()
+	return pool, unblock, dials
+}
+
+func TestGRPCPool_GetSlowDial(t *testing.T) {
+	pool, unblock, _ := createSlowDialPool(t, 3)
+	defer pool.Close()
+
+	first, err := pool.Get()
+	assert.Nil(t, err)
+
+	//hanging dials do not block callers when a connection is available
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		gConn, err := pool.Get()
+		assert.Nil(t, err)
+		assert.Equal(t, first, gConn)
+	}
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+	assert.Equal(t, 1, pool.Len())
+
+	close(unblock)
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 3
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestGRPCPool_GetSingleFlight(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+
+	var dials int32
+	pool.SetConnFactory(func(p *GRPCPool) (*grpc.ClientConn, error) {
+		atomic.AddInt32(&dials, 1)
+		time.Sleep(30 * time.Millisecond)
+		return grpc.Dial(addr, grpc.WithInsecure())
+	})
+
+	wg := sync.WaitGroup{}
+	conns := make([]*GrpcConn, 10)
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conns[i], _ = pool.Get()
+		}(i)
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&dials))
+	for _, conn := range conns {
+		assert.Equal(t, conns[0], conn)
+	}
+	assert.EqualValues(t, 10, conns[0].RefCount())
+}
+
+func TestGRPCPool_GetContextPendingDial(t *testing.T) {
+	pool, unblock, dials := createSlowDialPool(t, 1)
+	atomic.StoreInt32(dials, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := pool.GetContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	//connection dialed after close is closed
+	pool.lock.Lock()
+	call := pool.dialing[0]
+	pool.lock.Unlock()
+	pool.Close()
+	close(unblock)
+	<-call.done
+	assert.Equal(t, ErrPoolClosed, call.err)
+	assert.Nil(t, call.conn)
+	assert.Equal(t, 0, pool.Len())
+}
+
+func TestGRPCPool_InitConnectionsConcurrently(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(5, []string{addr})
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+
+	pool.SetConnFactory(func(p *GRPCPool) (*grpc.ClientConn, error) {
+		time.Sleep(50 * time.Millisecond)
+		return grpc.Dial(addr, grpc.WithInsecure())
+	})
+	start := time.Now()
+	assert.Nil(t, pool.InitConnections())
+	assert.True(t, time.Since(start) < 200*time.Millisecond)
+	assert.Equal(t, 5, pool.Len())
+	assert.Equal(t, ErrPoolInitialized, pool.InitConnections())
+	for _, conn := range pool.conns() {
+		assert.NotEqual(t, connectivity.Shutdown, conn.Conn().GetState())
+	}
+}
+
+//BenchmarkGRPCPool_GetSlowDial measures Get latency while dials of the rest connections hang
+func BenchmarkGRPCPool_GetSlowDial(b *testing.B) {
+	pool, unblock, _ := createSlowDialPool(b, 10)
+	defer pool.Close()
+	defer close(unblock)
+	first, _ := pool.Get()
+	first.Release()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			gConn, err := pool.Get()
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			gConn.Release()
+		}
+	})
+}
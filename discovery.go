@@ -0,0 +1,332 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	//defaultDNSInterval is the interval of dns lookups when it is not set
+	defaultDNSInterval = 30 * time.Second
+	//defaultFileInterval is the interval of file checks when it is not set
+	defaultFileInterval = time.Second
+)
+
+//Discovery is a source of targets of a service
+//Watch sends the current targets and then every change of them
+//targets are written in Options.Targets syntax , an empty set is never sent
+//the channel is closed after ctx is done
+type Discovery interface {
+	Watch(ctx context.Context) <-chan []string
+}
+
+//Watch apply targets from d to the cluster pool until ctx is done or the pool is closed
+//failed lookups keep the previous targets
+//when the pool is closed d is stopped by canceling its context
+func (server *ServerCluster) Watch(ctx context.Context, d Discovery) {
+	ctx, cancel := context.WithCancel(ctx)
+	updates := d.Watch(ctx)
+	pool := server.Pool
+	go func() {
+		defer cancel()
+		for closed := false; !closed; {
+			select {
+			case targets, ok := <-updates:
+				if !ok {
+					return
+				}
+				closed = pool.UpdateTargets(targets) == ErrPoolClosed
+			case <-pool.done:
+				closed = true
+			}
+		}
+		cancel()
+		//drain until d stops
+		for range updates {
+		}
+	}()
+}
+
+//lookupFunc return the current targets of a service
+type lookupFunc func(ctx context.Context) ([]string, error)
+
+//pollDiscovery call lookup every interval and send targets when they change
+func pollDiscovery(ctx context.Context, interval time.Duration, lookup lookupFunc) <-chan []string {
+	updates := make(chan []string)
+	go func() {
+		defer close(updates)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var last []string
+		for {
+			targets, err := lookup(ctx)
+			if err == nil && len(targets) > 0 && !equalTargets(last, targets) {
+				select {
+				case updates <- targets:
+					last = targets
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+//equalTargets report whether a and b are the same targets in the same order
+func equalTargets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//DNSResolver looks up dns records , *net.Resolver implements it
+type DNSResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+//DNSDiscovery is a Discovery of dns A or SRV records
+type DNSDiscovery struct {
+	//Resolver looks up records , net.DefaultResolver is used when it is nil
+	Resolver DNSResolver
+	//Interval is the interval of lookups , 30s when it is 0
+	Interval time.Duration
+
+	host    string
+	port    string
+	srv     bool
+	service string
+	proto   string
+}
+
+//NewDNSDiscovery return a Discovery of A records of host , every address is dialed with port
+func NewDNSDiscovery(host string, port int) *DNSDiscovery {
+	return &DNSDiscovery{host: host, port: strconv.Itoa(port)}
+}
+
+//NewDNSSRVDiscovery return a Discovery of SRV records , see net.LookupSRV for arguments
+//only records with the lowest priority are used , the others are backups
+//record weights are used as target weights
+func NewDNSSRVDiscovery(service, proto, name string) *DNSDiscovery {
+	return &DNSDiscovery{host: name, srv: true, service: service, proto: proto}
+}
+
+//Watch lookup records every Interval and send targets when they change
+func (d *DNSDiscovery) Watch(ctx context.Context) <-chan []string {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = defaultDNSInterval
+	}
+	return pollDiscovery(ctx, interval, d.lookup)
+}
+
+//lookup return sorted targets of the records
+func (d *DNSDiscovery) lookup(ctx context.Context) ([]string, error) {
+	var resolver DNSResolver = net.DefaultResolver
+	if d.Resolver != nil {
+		resolver = d.Resolver
+	}
+	var targets []string
+	if d.srv {
+		_, records, err := resolver.LookupSRV(ctx, d.service, d.proto, d.host)
+		if err != nil {
+			return nil, err
+		}
+		targets = srvTargets(records)
+	} else {
+		addrs, err := resolver.LookupHost(ctx, d.host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			targets = append(targets, net.JoinHostPort(addr, d.port))
+		}
+	}
+	//records come in random order
+	sort.Strings(targets)
+	return targets, nil
+}
+
+//srvTargets return targets of records with the lowest priority
+func srvTargets(records []*net.SRV) []string {
+	if len(records) == 0 {
+		return nil
+	}
+	priority := records[0].Priority
+	for _, srv := range records[1:] {
+		if srv.Priority < priority {
+			priority = srv.Priority
+		}
+	}
+	var targets []string
+	for _, srv := range records {
+		if srv.Priority != priority {
+			continue
+		}
+		weight := int(srv.Weight)
+		if weight > maxTargetWeight {
+			weight = maxTargetWeight
+		}
+		target := Target{
+			Addr:   net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))),
+			Weight: weight,
+		}
+		targets = append(targets, target.String())
+	}
+	return targets
+}
+
+//FileDiscovery is a Discovery of targets in a local file
+//the file is a JSON array or a YAML sequence of targets , YAML is used for .yaml and .yml files
+//it is checked every Interval and read again when its modification time or size changes
+type FileDiscovery struct {
+	//Interval is the interval of checks , 1s when it is 0
+	Interval time.Duration
+
+	path string
+}
+
+//NewFileDiscovery return a Discovery of targets in the file
+func NewFileDiscovery(path string) *FileDiscovery {
+	return &FileDiscovery{path: path}
+}
+
+//Watch check the file every Interval and send targets when they change
+//a missing or invalid file keeps the previous targets
+func (d *FileDiscovery) Watch(ctx context.Context) <-chan []string {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = defaultFileInterval
+	}
+	file := &watchedFile{path: d.path}
+	var targets []string
+	return pollDiscovery(ctx, interval, func(ctx context.Context) ([]string, error) {
+		var next []string
+		changed, err := file.read(&next)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			targets = next
+		}
+		return targets, nil
+	})
+}
+
+//ClusterDiscovery is a source of targets of many clusters by cluster name
+//Watch sends the current mapping and then every change of it , an empty mapping is never sent
+//the channel is closed after ctx is done
+type ClusterDiscovery interface {
+	Watch(ctx context.Context) <-chan map[string][]string
+}
+
+//ClusterFileDiscovery is a ClusterDiscovery of a local file
+//the file is a JSON or YAML object from cluster name to targets , YAML is used for .yaml and .yml files
+//it is checked every Interval and read again when its modification time or size changes
+type ClusterFileDiscovery struct {
+	//Interval is the interval of checks , 1s when it is 0
+	Interval time.Duration
+
+	path string
+}
+
+//NewClusterFileDiscovery return a ClusterDiscovery of cluster targets in the file
+func NewClusterFileDiscovery(path string) *ClusterFileDiscovery {
+	return &ClusterFileDiscovery{path: path}
+}
+
+//Watch check the file every Interval and send the mapping when it changes
+//a missing , invalid or empty file keeps the previous mapping , e.g. when it is read in the middle of writing
+func (d *ClusterFileDiscovery) Watch(ctx context.Context) <-chan map[string][]string {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = defaultFileInterval
+	}
+	updates := make(chan map[string][]string)
+	go func() {
+		defer close(updates)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		file := &watchedFile{path: d.path}
+		var last map[string][]string
+		for {
+			var next map[string][]string
+			if changed, err := file.read(&next); err == nil && changed && len(next) > 0 && !reflect.DeepEqual(last, next) {
+				select {
+				case updates <- next:
+					last = next
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+//watchedFile is a config file read again only after it changes
+type watchedFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	loaded  bool
+}
+
+//read decode the file into v when its modification time or size changes since the last successful read
+//it reports whether v is decoded
+func (f *watchedFile) read(v interface{}) (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+	if f.loaded && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return false, nil
+	}
+	if err := readConfigFile(f.path, v); err != nil {
+		return false, err
+	}
+	f.modTime, f.size, f.loaded = info.ModTime(), info.Size(), true
+	return true, nil
+}
+
+//readConfigFile decode a JSON or YAML file into v by its extension
+func readConfigFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, v)
+	default:
+		return json.Unmarshal(data, v)
+	}
+}
@@ -0,0 +1,269 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type fakeResolver struct {
+	lock  sync.Mutex
+	hosts []string
+	srvs  []*net.SRV
+	err   error
+}
+
+func (r *fakeResolver) set(hosts []string, srvs []*net.SRV, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.hosts, r.srvs, r.err = hosts, srvs, err
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.hosts, r.err
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return "", r.srvs, r.err
+}
+
+func receiveTargets(t *testing.T, updates <-chan []string) []string {
+	select {
+	case targets := <-updates:
+		return targets
+	case <-time.After(time.Second):
+		t.Fatal("no targets received")
+		return nil
+	}
+}
+
+func assertNoTargets(t *testing.T, updates <-chan []string) {
+	select {
+	case targets := <-updates:
+		t.Fatalf("unexpected targets %v", targets)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	resolver := &fakeResolver{}
+	resolver.set([]string{"10.0.0.2", "10.0.0.1"}, nil, nil)
+	d := NewDNSDiscovery("backend.local", 9999)
+	d.Resolver = resolver
+	d.Interval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+
+	updates := d.Watch(ctx)
+	assert.Equal(t, []string{"10.0.0.1:9999", "10.0.0.2:9999"}, receiveTargets(t, updates))
+
+	//same records in other order and failed lookups are not sent
+	resolver.set([]string{"10.0.0.1", "10.0.0.2"}, nil, nil)
+	assertNoTargets(t, updates)
+	resolver.set(nil, nil, errors.New("timeout"))
+	assertNoTargets(t, updates)
+	resolver.set(nil, nil, nil)
+	assertNoTargets(t, updates)
+
+	resolver.set([]string{"10.0.0.3", "::1"}, nil, nil)
+	assert.Equal(t, []string{"10.0.0.3:9999", "[::1]:9999"}, receiveTargets(t, updates))
+
+	cancel()
+	_, ok := <-updates
+	assert.False(t, ok)
+}
+
+func TestDNSSRVDiscovery(t *testing.T) {
+	resolver := &fakeResolver{}
+	resolver.set(nil, []*net.SRV{
+		{Target: "b.backend.local.", Port: 9999, Priority: 10, Weight: 3},
+		{Target: "c.backend.local.", Port: 9999, Priority: 20, Weight: 1},
+		{Target: "a.backend.local.", Port: 8888, Priority: 10, Weight: 1},
+		{Target: "d.backend.local.", Port: 9999, Priority: 10, Weight: 65535},
+	}, nil)
+	d := NewDNSSRVDiscovery("grpc", "tcp", "backend.local")
+	d.Resolver = resolver
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	//backups of higher priority are ignored
+	assert.Equal(t, []string{
+		"a.backend.local:8888",
+		"b.backend.local:9999;weight=3",
+		"d.backend.local:9999;weight=10000",
+	}, receiveTargets(t, d.Watch(ctx)))
+}
+
+func TestFileDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcpool")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		name    string
+		content string
+	}{
+		{"targets.json", `["127.0.0.1:80", "127.0.0.1:81;weight=2"]`},
+		{"targets.yaml", "- 127.0.0.1:80\n- 127.0.0.1:81;weight=2\n"},
+	} {
+		path := filepath.Join(dir, c.name)
+		d := NewFileDiscovery(path)
+		d.Interval = 5 * time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		updates := d.Watch(ctx)
+
+		//missing file is waited
+		assertNoTargets(t, updates)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(c.content), 0644))
+		assert.Equal(t, []string{"127.0.0.1:80", "127.0.0.1:81;weight=2"}, receiveTargets(t, updates), c.name)
+
+		//invalid content keeps the previous targets
+		assert.Nil(t, ioutil.WriteFile(path, []byte("{"), 0644))
+		assertNoTargets(t, updates)
+		//malformed content must not crash the watcher
+		assert.Nil(t, ioutil.WriteFile(path, []byte("0: [:!00 \xef"), 0644))
+		assertNoTargets(t, updates)
+
+		assert.Nil(t, ioutil.WriteFile(path, []byte(`["127.0.0.1:82"]`), 0644))
+		assert.Equal(t, []string{"127.0.0.1:82"}, receiveTargets(t, updates), c.name)
+		cancel()
+	}
+}
+
+func TestServerCluster_Watch(t *testing.T) {
+	addr1, stop1 := startTestingServer(t)
+	defer stop1()
+	addr2, stop2 := startTestingServer(t)
+	defer stop2()
+	opt, _ := NewOptions(2, []string{addr1})
+	sc, _ := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	defer sc.Pool.Close()
+
+	host1, port1, _ := net.SplitHostPort(addr1)
+	host2, port2, _ := net.SplitHostPort(addr2)
+	resolver := &fakeResolver{}
+	resolver.set(nil, []*net.SRV{{Target: host2, Port: mustAtoi(port2)}}, nil)
+	d := NewDNSSRVDiscovery("grpc", "tcp", "backend.local")
+	d.Resolver = resolver
+	d.Interval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sc.Watch(ctx, d)
+	assert.Eventually(t, func() bool {
+		targets := sc.Pool.Targets()
+		return len(targets) == 1 && targets[0].Addr == addr2
+	}, time.Second, 5*time.Millisecond)
+	conn, err := sc.GetClient()
+	assert.Nil(t, err)
+	assert.Equal(t, addr2, conn.Target())
+	conn.Release()
+
+	resolver.set(nil, []*net.SRV{{Target: host1, Port: mustAtoi(port1), Weight: 1}, {Target: host2, Port: mustAtoi(port2), Weight: 1}}, nil)
+	assert.Eventually(t, func() bool {
+		return len(sc.Pool.Targets()) == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestServerCluster_WatchPoolClosed(t *testing.T) {
+	opt, _ := NewOptions(2, []string{"127.0.0.1:80"})
+	sc, _ := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	stopped := make(chan struct{})
+	d := discoveryFunc(func(ctx context.Context) <-chan []string {
+		updates := make(chan []string)
+		go func() {
+			defer close(stopped)
+			defer close(updates)
+			<-ctx.Done()
+		}()
+		return updates
+	})
+
+	//d is stopped after the pool is closed though ctx is never done
+	sc.Watch(context.Background(), d)
+	sc.Pool.Close()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("discovery not stopped")
+	}
+}
+
+type discoveryFunc func(ctx context.Context) <-chan []string
+
+func (fn discoveryFunc) Watch(ctx context.Context) <-chan []string {
+	return fn(ctx)
+}
+
+func mustAtoi(s string) uint16 {
+	n, _ := strconv.Atoi(s)
+	return uint16(n)
+}
+
+func TestClusterFileDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcpool")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "clusters.yml")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("server1:\n  - 127.0.0.1:80\nserver2:\n  - 127.0.0.1:81;weight=2\n"), 0644))
+	d := NewClusterFileDiscovery(path)
+	d.Interval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := d.Watch(ctx)
+
+	select {
+	case mapping := <-updates:
+		assert.Equal(t, map[string][]string{
+			"server1": {"127.0.0.1:80"},
+			"server2": {"127.0.0.1:81;weight=2"},
+		}, mapping)
+	case <-time.After(time.Second):
+		t.Fatal("no mapping received")
+	}
+
+	//invalid or empty content keeps the previous mapping
+	assert.Nil(t, ioutil.WriteFile(path, []byte(""), 0644))
+	select {
+	case mapping := <-updates:
+		t.Fatalf("unexpected mapping %v", mapping)
+	case <-time.After(30 * time.Millisecond):
+	}
+	assert.Nil(t, ioutil.WriteFile(path, []byte("server1: ["), 0644))
+	select {
+	case mapping := <-updates:
+		t.Fatalf("unexpected mapping %v", mapping)
+	case <-time.After(30 * time.Millisecond):
+	}
+	assert.Nil(t, ioutil.WriteFile(path, []byte("0: [:!00 \xef"), 0644))
+	select {
+	case mapping := <-updates:
+		t.Fatalf("unexpected mapping %v", mapping)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("server1: [127.0.0.1:82]\n"), 0644))
+	select {
+	case mapping := <-updates:
+		assert.Equal(t, map[string][]string{"server1": {"127.0.0.1:82"}}, mapping)
+	case <-time.After(time.Second):
+		t.Fatal("no mapping received")
+	}
+
+	cancel()
+	_, ok := <-updates
+	assert.False(t, ok)
+}
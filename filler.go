@@ -0,0 +1,72 @@
+package pool
+
+import (
+	"time"
+)
+
+const (
+	//fillCheckInterval is how often the filler checks pool size without signals
+	fillCheckInterval = time.Second
+	//fillRetryMinDelay is the first wait of the filler after a failed dial
+	fillRetryMinDelay = 50 * time.Millisecond
+	//fillRetryMaxDelay is the upper bound of the filler backoff
+	fillRetryMaxDelay = 10 * time.Second
+)
+
+//signalFill wake up the background filler without blocking
+func (p *GRPCPool) signalFill() {
+	select {
+	case p.fillSignal <- struct{}{}:
+	default:
+	}
+}
+
+//fillLoop keep at least minIdle connections in pool until stop is closed
+//failed dials are retried with exponential backoff
+func (p *GRPCPool) fillLoop(stop <-chan struct{}, minIdle int) {
+	ticker := time.NewTicker(fillCheckInterval)
+	defer ticker.Stop()
+
+	delay := fillRetryMinDelay
+	for {
+		if err := p.fill(minIdle); err != nil {
+			timer := time.NewTimer(delay)
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if delay *= 2; delay > fillRetryMaxDelay {
+				delay = fillRetryMaxDelay
+			}
+			continue
+		}
+		delay = fillRetryMinDelay
+
+		select {
+		case <-stop:
+			return
+		case <-p.fillSignal:
+		case <-ticker.C:
+		}
+	}
+}
+
+//fill dial connections until pool has minIdle alive ones
+//pending dials are counted , so the filler never dials more than needed or over the cap
+func (p *GRPCPool) fill(minIdle int) error {
+	p.lock.Lock()
+	if p.isClosed() {
+		p.lock.Unlock()
+		return nil
+	}
+	p.removeDead()
+	var calls []*dialCall
+	for p.Len()+len(p.dialing) < minIdle && p.Len()+len(p.dialing) < p.Cap() {
+		calls = append(calls, p.startDial())
+	}
+	p.lock.Unlock()
+
+	return waitDials(calls)
+}
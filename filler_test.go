@@ -0,0 +1,96 @@
+package pool
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestGRPCPool_MinIdle(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(3, []string{addr})
+	opt.MinIdle = 2
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+
+	//warmed up without any Get
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	//refilled after eviction
+	pool.lock.Lock()
+	evicted := pool.conns()[0]
+	pool.evict(evicted)
+	pool.lock.Unlock()
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, poolContains(pool, evicted))
+}
+
+func TestGRPCPool_MinIdleInitConnections(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(4, []string{addr})
+	opt.MinIdle = 2
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+
+	//connections of the filler are topped up to cap
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, pool.InitConnections())
+	assert.Equal(t, 4, pool.Len())
+	assert.Equal(t, ErrPoolInitialized, pool.InitConnections())
+}
+
+func TestGRPCPool_MinIdleBackoff(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(3, []string{addr})
+	opt.MinIdle = 1
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	var dials int32
+	pool.SetTargetConnFactory(func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		if atomic.AddInt32(&dials, 1) <= 3 {
+			return nil, errors.New("dial failed")
+		}
+		return defaultTargetFactoryCreateConn()(p, target)
+	})
+	pool.lock.Lock()
+	pool.evict(pool.conns()[0])
+	pool.lock.Unlock()
+
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 4, atomic.LoadInt32(&dials))
+}
+
+func TestGRPCPool_MinIdleKeptByJanitor(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(3, []string{addr})
+	opt.MinIdle = 1
+	opt.MaxIdleTime = 20 * time.Millisecond
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+	assert.Nil(t, pool.InitConnections())
+
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 1
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, pool.Len())
+}
@@ -0,0 +1,52 @@
+package pool
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+//NewServerBuilder return a ServerBuilderFunc of a typed client builder
+//it is for SetClientBuilders , clients are got back with GetClient
+func NewServerBuilder[T any](fn func(conn grpc.ClientConnInterface) T) ServerBuilderFunc {
+	return func(conn grpc.ClientConnInterface) interface{} {
+		return fn(conn)
+	}
+}
+
+//RegisterClient set the typed client builder of servname on server
+//
+//	RegisterClient(cluster, "say", helloworld.NewHelloWorldClient)
+func RegisterClient[T any](server *ServerCluster, servname string, fn func(conn grpc.ClientConnInterface) T) {
+	server.SetClientBuilder(servname, NewServerBuilder(fn))
+}
+
+//GetClient return the typed client of servname like ServerCluster.GetServerClient
+//ErrClientType is returned when the builder of servname does not build T
+//
+//	client, release, err := GetClient[helloworld.HelloWorldClient](cluster, "say")
+func GetClient[T any](server *ServerCluster, servname string) (T, func(), error) {
+	client, release, err := server.GetServerClient(servname)
+	return typedClient[T](client, release, err)
+}
+
+//GetClientContext return the typed client of servname like ServerCluster.GetServerClientContext
+//ErrClientType is returned when the builder of servname does not build T
+func GetClientContext[T any](ctx context.Context, server *ServerCluster, servname string) (T, func(), error) {
+	client, release, err := server.GetServerClientContext(ctx, servname)
+	return typedClient[T](client, release, err)
+}
+
+//typedClient assert client to T , the connection is released when it fails
+func typedClient[T any](client interface{}, release func(), err error) (T, func(), error) {
+	var zero T
+	if err != nil {
+		return zero, nil, err
+	}
+	typed, ok := client.(T)
+	if !ok {
+		release()
+		return zero, nil, ErrClientType
+	}
+	return typed, release, nil
+}
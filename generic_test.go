@@ -0,0 +1,52 @@
+package pool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func newTestingClient(conn grpc.ClientConnInterface) iTestingBuilder {
+	return &testingBuilder{}
+}
+
+func TestGetClient(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	cluster, err := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	assert.Nil(t, err)
+	defer cluster.Pool.Close()
+
+	RegisterClient(cluster, "typed", newTestingClient)
+	//builders of interface{} are still supported
+	cluster.SetClientBuilders(map[string]ServerBuilderFunc{
+		"default": clientBuilder,
+		"builder": NewServerBuilder(newTestingClient),
+	})
+
+	for _, servname := range []string{"typed", "default", "builder"} {
+		client, release, err := GetClient[iTestingBuilder](cluster, servname)
+		assert.Nil(t, err)
+		assert.NotNil(t, client)
+		assert.EqualValues(t, 1, cluster.Pool.Stats().InUse)
+		release()
+	}
+
+	concrete, release, err := GetClientContext[*testingBuilder](context.Background(), cluster, "typed")
+	assert.Nil(t, err)
+	assert.NotNil(t, concrete)
+	release()
+
+	//mismatched type releases the connection
+	wrong, release, err := GetClient[grpc.ClientConnInterface](cluster, "typed")
+	assert.Equal(t, ErrClientType, err)
+	assert.Nil(t, wrong)
+	assert.Nil(t, release)
+	assert.EqualValues(t, 0, cluster.Pool.Stats().InUse)
+
+	_, _, err = GetClient[iTestingBuilder](cluster, "missing")
+	assert.Equal(t, ErrServerBuilderNil, err)
+}
@@ -0,0 +1,73 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+//healthCheckLoop check connections every interval until stop is closed
+func (p *GRPCPool) healthCheckLoop(stop <-chan struct{}, interval, timeout time.Duration, service string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.checkHealth(timeout, service)
+		}
+	}
+}
+
+//checkHealth call grpc.health.v1.Health/Check on every pooled connection
+//failing connections are evicted and replaced by new ones
+func (p *GRPCPool) checkHealth(timeout time.Duration, service string) {
+	conns := p.conns()
+
+	//check connections concurrently , so one hanging backend does not delay others
+	failed := make([]bool, len(conns))
+	wg := sync.WaitGroup{}
+	for i, conn := range conns {
+		if conn.conn == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, conn *GrpcConn) {
+			defer wg.Done()
+			failed[i] = !checkConnHealth(conn.conn, timeout, service)
+		}(i, conn)
+	}
+	wg.Wait()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.isClosed() {
+		return
+	}
+	evicted := 0
+	for i, conn := range conns {
+		if failed[i] && p.evict(conn) {
+			evicted++
+		}
+	}
+	//redial replacements of evicted connections
+	p.refill(evicted)
+}
+
+//checkConnHealth return if the server behind conn is serving
+//servers without health service are treated as healthy
+func checkConnHealth(conn *grpc.ClientConn, timeout time.Duration, service string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return status.Code(err) == codes.Unimplemented
+	}
+	return resp.Status == healthpb.HealthCheckResponse_SERVING
+}
@@ -0,0 +1,115 @@
+package pool
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+//startHealthServer run an in-process grpc server with health service
+func startHealthServer(t testing.TB) (string, *health.Server, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	return lis.Addr().String(), healthServer, server.Stop
+}
+
+func createHealthCheckPool(addr string, service string) *GRPCPool {
+	opt, _ := NewOptions(2, []string{addr})
+	opt.HealthCheckInterval = 10 * time.Millisecond
+	opt.HealthCheckTimeout = 100 * time.Millisecond
+	opt.HealthCheckService = service
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	_ = pool.InitConnections()
+	return pool
+}
+
+func poolContains(p *GRPCPool, conn *GrpcConn) bool {
+	for _, c := range p.conns() {
+		if c == conn {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGRPCPool_HealthCheck(t *testing.T) {
+	addr, healthServer, stop := startHealthServer(t)
+	defer stop()
+
+	pool := createHealthCheckPool(addr, "")
+	defer pool.Close()
+	conns := append([]*GrpcConn{}, pool.conns()...)
+
+	//serving connections are kept
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, poolContains(pool, conns[0]))
+	assert.True(t, poolContains(pool, conns[1]))
+
+	//in-use connection is evicted but closed after release
+	gConn, err := pool.Get()
+	assert.Nil(t, err)
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.Eventually(t, func() bool {
+		return !poolContains(pool, conns[0]) && !poolContains(pool, conns[1])
+	}, time.Second, 10*time.Millisecond)
+	idle := conns[0]
+	if idle == gConn {
+		idle = conns[1]
+	}
+	assert.Eventually(t, func() bool {
+		return idle.Conn().GetState() == connectivity.Shutdown
+	}, time.Second, 10*time.Millisecond)
+	assert.NotEqual(t, connectivity.Shutdown, gConn.Conn().GetState())
+	gConn.Release()
+	assert.Equal(t, connectivity.Shutdown, gConn.Conn().GetState())
+
+	//replacements are dialed
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestGRPCPool_HealthCheckService(t *testing.T) {
+	addr, healthServer, stop := startHealthServer(t)
+	defer stop()
+	healthServer.SetServingStatus("demo", healthpb.HealthCheckResponse_SERVING)
+
+	pool := createHealthCheckPool(addr, "demo")
+	defer pool.Close()
+	conn := pool.conns()[0]
+
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, poolContains(pool, conn))
+
+	healthServer.SetServingStatus("demo", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.Eventually(t, func() bool {
+		return !poolContains(pool, conn)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestGRPCPool_HealthCheckUnimplemented(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+
+	pool := createHealthCheckPool(addr, "")
+	defer pool.Close()
+	conn := pool.conns()[0]
+
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, poolContains(pool, conn))
+}
@@ -0,0 +1,84 @@
+package pool
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+//janitorMinInterval is the lower bound of janitor checking interval
+const janitorMinInterval = time.Millisecond
+
+//janitorLoop retire idle and aged connections until stop is closed
+func (p *GRPCPool) janitorLoop(stop <-chan struct{}, maxIdle, maxLifetime time.Duration, minIdle int) {
+	ticker := time.NewTicker(janitorInterval(maxIdle, maxLifetime))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			p.reap(now, maxIdle, maxLifetime, minIdle)
+		}
+	}
+}
+
+//reap close connections idle longer than maxIdle but keep at least minIdle connections
+//and replace connections older than maxLifetime , an aged connection keeps serving until its replacement
+//is put into pool and then it is drained , at most a quarter of connections are replaced at the same time
+//so connections dialed together are not all redialed at once
+func (p *GRPCPool) reap(now time.Time, maxIdle, maxLifetime time.Duration, minIdle int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.isClosed() {
+		return
+	}
+
+	var expired, aged []*GrpcConn
+	conns := p.conns()
+	kept := len(conns)
+	replacing := 0
+	for _, conn := range conns {
+		if p.replacing(conn) {
+			replacing++
+			continue
+		}
+		if maxLifetime > 0 && now.Sub(conn.createdAt) > maxLifetime {
+			aged = append(aged, conn)
+			continue
+		}
+		lastUsed := time.Unix(0, atomic.LoadInt64(&conn.lastUsed))
+		if maxIdle > 0 && kept > minIdle && conn.RefCount() == 0 && now.Sub(lastUsed) > maxIdle {
+			expired = append(expired, conn)
+			kept--
+		}
+	}
+	for _, conn := range expired {
+		p.evict(conn)
+	}
+	//the oldest are replaced first
+	sort.Slice(aged, func(i, j int) bool {
+		return aged[i].createdAt.Before(aged[j].createdAt)
+	})
+	for i := 0; i < len(aged) && replacing < maxReplacing(len(conns)); i++ {
+		p.startReplace(aged[i])
+		replacing++
+	}
+}
+
+//maxReplacing return the max number of aged connections replaced at the same time in a pool of n connections
+func maxReplacing(n int) int {
+	return (n + 3) / 4
+}
+
+//janitorInterval return half of the shortest non-zero duration
+func janitorInterval(maxIdle, maxLifetime time.Duration) time.Duration {
+	interval := maxIdle
+	if interval == 0 || (maxLifetime > 0 && maxLifetime < interval) {
+		interval = maxLifetime
+	}
+	if interval /= 2; interval < janitorMinInterval {
+		interval = janitorMinInterval
+	}
+	return interval
+}
@@ -0,0 +1,119 @@
+package pool
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestJanitorInterval(t *testing.T) {
+	assert.Equal(t, 5*time.Second, janitorInterval(10*time.Second, 0))
+	assert.Equal(t, 5*time.Second, janitorInterval(0, 10*time.Second))
+	assert.Equal(t, time.Second, janitorInterval(10*time.Second, 2*time.Second))
+	assert.Equal(t, janitorMinInterval, janitorInterval(time.Nanosecond, 0))
+}
+
+func TestGRPCPool_MaxIdleTime(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{addr})
+	opt.MaxIdleTime = 30 * time.Millisecond
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+	_ = pool.InitConnections()
+
+	busy, err := pool.Get()
+	assert.Nil(t, err)
+	idle := pool.conns()[0]
+	if idle == busy {
+		idle = pool.conns()[1]
+	}
+
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, poolContains(pool, busy))
+	assert.Equal(t, connectivity.Shutdown, idle.Conn().GetState())
+
+	busy.Release()
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestGRPCPool_MaxConnLifetime(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{addr})
+	opt.MaxConnLifetime = 30 * time.Millisecond
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+	_ = pool.InitConnections()
+
+	busy, err := pool.Get()
+	assert.Nil(t, err)
+	aged := append([]*GrpcConn{}, pool.conns()...)
+
+	assert.Eventually(t, func() bool {
+		return !poolContains(pool, aged[0]) && !poolContains(pool, aged[1]) && pool.Len() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	//aged connection is drained before closing
+	assert.NotEqual(t, connectivity.Shutdown, busy.Conn().GetState())
+	busy.Release()
+	assert.Equal(t, connectivity.Shutdown, busy.Conn().GetState())
+}
+
+func TestGRPCPool_MaxConnLifetimeStaggered(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(4, []string{addr})
+	opt.MaxConnLifetime = 30 * time.Millisecond
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+	_ = pool.InitConnections()
+	aged := append([]*GrpcConn{}, pool.conns()...)
+
+	//slow dials show how many connections are replaced at the same time
+	var dialing, maxDialing int32
+	pool.SetTargetConnFactory(func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		n := atomic.AddInt32(&dialing, 1)
+		defer atomic.AddInt32(&dialing, -1)
+		for {
+			m := atomic.LoadInt32(&maxDialing)
+			if n <= m || atomic.CompareAndSwapInt32(&maxDialing, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return grpc.Dial(target, p.GetDialOptions()...)
+	})
+
+	//the pool is never short of connections while they are replaced
+	deadline := time.Now().Add(2 * time.Second)
+	for replaced := false; !replaced; {
+		if time.Now().After(deadline) {
+			t.Fatal("aged connections are not replaced")
+		}
+		assert.True(t, pool.Len() >= 4)
+		replaced = true
+		for _, conn := range aged {
+			if poolContains(pool, conn) {
+				replaced = false
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&maxDialing))
+}
+
+func TestMaxReplacing(t *testing.T) {
+	assert.Equal(t, 1, maxReplacing(1))
+	assert.Equal(t, 1, maxReplacing(4))
+	assert.Equal(t, 2, maxReplacing(5))
+	assert.Equal(t, 25, maxReplacing(100))
+}
@@ -0,0 +1,195 @@
+package pool
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+//leakMaxStackDepth is the max frames recorded for each lease
+const leakMaxStackDepth = 32
+
+//LeakDetectionOptions enables debug tracking of connections got from pool
+//the stack of each acquisition is recorded , so it costs and is meant for debugging
+type LeakDetectionOptions struct {
+	//Threshold reports leases held longer than it , once for each lease
+	Threshold time.Duration
+	//Interval between two checks , Threshold is used when it is 0
+	Interval time.Duration
+	//Reporter receives leaks , it must be safe for concurrent use
+	Reporter LeakReporter
+}
+
+//validate leak detection option if available
+func (l LeakDetectionOptions) validate() error {
+	if l.Threshold <= 0 ||
+		l.Interval < 0 ||
+		l.Reporter == nil {
+		return ErrOptionValid
+	}
+	return nil
+}
+
+//interval return the interval between two checks
+func (l LeakDetectionOptions) interval() time.Duration {
+	if l.Interval > 0 {
+		return l.Interval
+	}
+	return l.Threshold
+}
+
+//Leak describes a lease held too long or garbage collected without release
+type Leak struct {
+	//Target is the target address of the leased connection
+	Target string
+	//AcquiredAt is when the lease is got from pool
+	AcquiredAt time.Time
+	//Held is how long the lease is held when reported
+	Held time.Duration
+	//Stack is the stack trace of the acquisition
+	Stack string
+	//Collected is true when the Lease is garbage collected without Release
+	Collected bool
+}
+
+//String return the leak with its acquisition stack
+func (l Leak) String() string {
+	reason := "held for " + l.Held.String()
+	if l.Collected {
+		reason = "garbage collected without release after " + l.Held.String()
+	}
+	return fmt.Sprintf("grpcpool: connection to %s %s , acquired at:\n%s", l.Target, reason, l.Stack)
+}
+
+//LeakReporter receives leaks found by leak detection
+type LeakReporter interface {
+	ReportLeak(leak Leak)
+}
+
+//LeakReporterFunc is an adapter to use an ordinary function as LeakReporter
+type LeakReporterFunc func(leak Leak)
+
+//ReportLeak calls fn(leak)
+func (fn LeakReporterFunc) ReportLeak(leak Leak) {
+	fn(leak)
+}
+
+//leaseInfo is one tracked acquisition of a connection
+type leaseInfo struct {
+	conn       *GrpcConn
+	acquiredAt time.Time
+	stack      string
+	//owned is true when the lease is released by its Lease instead of GrpcConn.Release
+	owned    bool
+	reported bool
+}
+
+//leak return the Leak of lease at now
+func (l *leaseInfo) leak(now time.Time, collected bool) Leak {
+	return Leak{
+		Target:     l.conn.Target(),
+		AcquiredAt: l.acquiredAt,
+		Held:       now.Sub(l.acquiredAt),
+		Stack:      l.stack,
+		Collected:  collected,
+	}
+}
+
+//leakDetector tracks outstanding leases of a pool
+type leakDetector struct {
+	opt  LeakDetectionOptions
+	lock sync.Mutex
+	//leases are outstanding leases of each connection in acquisition order
+	leases map[*GrpcConn][]*leaseInfo
+}
+
+//newLeakDetector return a leakDetector with opt
+func newLeakDetector(opt LeakDetectionOptions) *leakDetector {
+	return &leakDetector{opt: opt, leases: make(map[*GrpcConn][]*leaseInfo)}
+}
+
+//track record a lease of conn with the stack of the caller
+func (d *leakDetector) track(conn *GrpcConn, owned bool) *leaseInfo {
+	info := &leaseInfo{conn: conn, acquiredAt: time.Now(), stack: callerStack(3), owned: owned}
+	d.lock.Lock()
+	d.leases[conn] = append(d.leases[conn], info)
+	d.lock.Unlock()
+	return info
+}
+
+//untrack remove info from leases of conn
+//when info is nil , GrpcConn.Release can not tell its lease , so the oldest one not owned by a Lease is removed
+func (d *leakDetector) untrack(conn *GrpcConn, info *leaseInfo) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	leases := d.leases[conn]
+	for i, l := range leases {
+		if l == info || (info == nil && !l.owned) {
+			leases = append(leases[:i:i], leases[i+1:]...)
+			if len(leases) == 0 {
+				delete(d.leases, conn)
+			} else {
+				d.leases[conn] = leases
+			}
+			return true
+		}
+	}
+	return false
+}
+
+//collected report info garbage collected without release
+func (d *leakDetector) collected(info *leaseInfo) {
+	if d.untrack(info.conn, info) {
+		d.opt.Reporter.ReportLeak(info.leak(time.Now(), true))
+	}
+}
+
+//check report leases held longer than threshold at now
+func (d *leakDetector) check(now time.Time) {
+	var leaks []Leak
+	d.lock.Lock()
+	for _, leases := range d.leases {
+		for _, l := range leases {
+			if !l.reported && now.Sub(l.acquiredAt) > d.opt.Threshold {
+				l.reported = true
+				leaks = append(leaks, l.leak(now, false))
+			}
+		}
+	}
+	d.lock.Unlock()
+	for _, leak := range leaks {
+		d.opt.Reporter.ReportLeak(leak)
+	}
+}
+
+//callerStack return the stack trace skipping skip frames , 0 is callerStack itself
+func callerStack(skip int) string {
+	pcs := make([]uintptr, leakMaxStackDepth)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+//leakLoop report leases held too long until pool is closed
+func (p *GRPCPool) leakLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case now := <-ticker.C:
+			p.leaks.check(now)
+		}
+	}
+}
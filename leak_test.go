@@ -0,0 +1,107 @@
+package pool
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+//createLeakDetectionPool return a pool reporting leaks to the returned channel
+func createLeakDetectionPool(t *testing.T, addr string, threshold time.Duration) (*GRPCPool, chan Leak) {
+	leaks := make(chan Leak, 10)
+	opt, _ := NewOptions(1, []string{addr})
+	opt.LeakDetection = &LeakDetectionOptions{
+		Threshold: threshold,
+		Interval:  5 * time.Millisecond,
+		Reporter: LeakReporterFunc(func(leak Leak) {
+			leaks <- leak
+		}),
+	}
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	return pool, leaks
+}
+
+func TestGRPCPool_LeakDetection(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	pool, leaks := createLeakDetectionPool(t, addr, 20*time.Millisecond)
+	defer pool.Close()
+
+	//released in time
+	conn, err := pool.Get()
+	assert.Nil(t, err)
+	conn.Release()
+
+	conn, err = pool.Get()
+	assert.Nil(t, err)
+	select {
+	case leak := <-leaks:
+		assert.Equal(t, addr, leak.Target)
+		assert.False(t, leak.Collected)
+		assert.True(t, leak.Held > 20*time.Millisecond)
+		assert.True(t, strings.Contains(leak.Stack, "TestGRPCPool_LeakDetection"), leak.Stack)
+		assert.True(t, strings.Contains(leak.String(), "held for"))
+	case <-time.After(time.Second):
+		t.Fatal("leak is not reported")
+	}
+	//reported once
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(t, leaks, 0)
+	conn.Release()
+	assert.Len(t, pool.leaks.leases, 0)
+}
+
+//leakServerClient get a client and drop its release func
+func leakServerClient(t *testing.T, server *ServerCluster) {
+	_, _, err := server.GetServerClient("default")
+	assert.Nil(t, err)
+}
+
+func TestGRPCPool_LeakDetectionCollected(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	pool, leaks := createLeakDetectionPool(t, addr, time.Hour)
+	defer pool.Close()
+	server := &ServerCluster{Name: "server1", Pool: pool, clientBuilder: map[string]ServerBuilderFunc{"default": clientBuilder}}
+
+	//a released client is not reported
+	_, release, err := server.GetServerClient("default")
+	assert.Nil(t, err)
+	release()
+	release()
+	assert.EqualValues(t, 0, pool.InUse())
+
+	leakServerClient(t, server)
+	for i := 0; i < 100 && len(leaks) == 0; i++ {
+		runtime.GC()
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case leak := <-leaks:
+		assert.True(t, leak.Collected)
+		assert.True(t, strings.Contains(leak.Stack, "leakServerClient"), leak.Stack)
+		assert.True(t, strings.Contains(leak.String(), "garbage collected"))
+	default:
+		t.Fatal("collected lease is not reported")
+	}
+	assert.Len(t, leaks, 0)
+	assert.Len(t, pool.leaks.leases, 0)
+}
+
+func TestLeakDetectionOptions_validate(t *testing.T) {
+	reporter := LeakReporterFunc(func(leak Leak) {})
+	assert.Nil(t, LeakDetectionOptions{Threshold: time.Second, Reporter: reporter}.validate())
+	assert.Equal(t, time.Second, LeakDetectionOptions{Threshold: time.Second}.interval())
+	assert.Equal(t, ErrOptionValid, LeakDetectionOptions{Reporter: reporter}.validate())
+	assert.Equal(t, ErrOptionValid, LeakDetectionOptions{Threshold: time.Second}.validate())
+
+	opt, _ := NewOptions(1, []string{"127.0.0.1:9999"})
+	opt.LeakDetection = &LeakDetectionOptions{Threshold: -time.Second, Reporter: reporter}
+	_, err := NewGRPCPool(opt)
+	assert.Equal(t, ErrOptionValid, err)
+}
@@ -0,0 +1,71 @@
+package pool
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+)
+
+//Lease is one acquisition of a pooled connection
+//connections are shared by leases , so a connection removed from pool is closed only after its last lease is released
+type Lease struct {
+	conn *GrpcConn
+	//info is the tracked acquisition with leak detection , nil otherwise
+	info     *leaseInfo
+	released int32
+}
+
+//Conn return the *grpc.ClientConn of the lease
+func (l *Lease) Conn() *grpc.ClientConn {
+	return l.conn.conn
+}
+
+//Target return the target address of the leased connection
+func (l *Lease) Target() string {
+	return l.conn.Target()
+}
+
+//Release put the connection back to pool
+//it is idempotent and safe to call concurrently , only the first call releases the connection
+func (l *Lease) Release() {
+	if atomic.CompareAndSwapInt32(&l.released, 0, 1) {
+		l.conn.release(l.info)
+	}
+}
+
+//Acquire return a Lease of a connection , it waits for an available connection like GetContext
+//it returns ctx.Err() when ctx is done before that
+func (p *GRPCPool) Acquire(ctx context.Context) (*Lease, error) {
+	return p.lease(ctx, true)
+}
+
+//lease get a connection and return its Lease
+//when wait is true it waits for an available connection like GetContext
+//with leak detection the lease is tracked and reported when it is garbage collected without Release
+func (p *GRPCPool) lease(ctx context.Context, wait bool) (*Lease, error) {
+	var conn *GrpcConn
+	var err error
+	if wait {
+		conn, err = p.getContext(ctx)
+	} else {
+		conn, err = p.get(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.leaks == nil {
+		return &Lease{conn: conn}, nil
+	}
+	l := &Lease{conn: conn, info: p.leaks.track(conn, true)}
+	runtime.SetFinalizer(l, func(l *Lease) {
+		l.conn.pool.leaks.collected(l.info)
+	})
+	return l, nil
+}
+
+//Acquire return a Lease of a connection of the cluster pool , see GRPCPool.Acquire
+func (server *ServerCluster) Acquire(ctx context.Context) (*Lease, error) {
+	return server.Pool.Acquire(ctx)
+}
@@ -0,0 +1,113 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestGRPCPool_Acquire(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	opt.MaxStreamsPerConn = 2
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	lease1, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, addr, lease1.Target())
+	assert.NotNil(t, lease1.Conn())
+	lease2, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	conn := lease1.conn
+	assert.True(t, conn == lease2.conn)
+	assert.EqualValues(t, 2, conn.RefCount())
+
+	//waits until ctx is done when exhausted
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	//releasing concurrently and repeatedly only releases once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lease1.Release()
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, conn.RefCount())
+	lease2.Release()
+	lease2.Release()
+	assert.EqualValues(t, 0, conn.RefCount())
+
+	//extra GrpcConn.Release never drives refcount negative
+	c, err := pool.Get()
+	assert.Nil(t, err)
+	c.Release()
+	c.Release()
+	assert.EqualValues(t, 0, conn.RefCount())
+}
+
+func TestLease_ReleaseRetired(t *testing.T) {
+	addr1, stop1 := startTestingServer(t)
+	defer stop1()
+	addr2, stop2 := startTestingServer(t)
+	defer stop2()
+	opt, _ := NewOptions(1, []string{addr1})
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	lease1, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	lease2, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	conn := lease1.conn
+
+	assert.EqualValues(t, 2, conn.RefCount())
+
+	//the connection removed from pool is kept until its last lease is released
+	assert.Nil(t, pool.UpdateTargets([]string{addr2}))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&conn.retired) == retiredDraining
+	}, time.Second, 5*time.Millisecond)
+	lease1.Release()
+	lease1.Release()
+	assert.EqualValues(t, 1, conn.RefCount())
+	assert.EqualValues(t, retiredDraining, atomic.LoadInt32(&conn.retired))
+	lease2.Release()
+	assert.EqualValues(t, retiredClosed, atomic.LoadInt32(&conn.retired))
+
+	lease, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, addr2, lease.Target())
+	lease.Release()
+}
+
+func TestServerCluster_Acquire(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	pool, leaks := createLeakDetectionPool(t, addr, time.Hour)
+	defer pool.Close()
+	server := &ServerCluster{Name: "server1", Pool: pool}
+
+	lease, err := server.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, pool.leaks.leases[lease.conn], 1)
+	lease.Release()
+	lease.Release()
+	assert.Len(t, pool.leaks.leases, 0)
+	assert.EqualValues(t, 0, pool.InUse())
+	assert.Len(t, leaks, 0)
+}
@@ -25,27 +25,88 @@ var (
 	ErrConnConnect = errors.New("failed to get connection too many times")
 	//ErrServerBuilderNil error when server build is nil
 	ErrServerBuilderNil = errors.New("server client builder is nil")
+	//ErrPoolExhausted error when every connection reaches max streams
+	ErrPoolExhausted = errors.New("all connections reach max streams")
+	//ErrTargetInvalid error when target syntax or weight is invalid
+	ErrTargetInvalid = errors.New("target is invalid")
+	//ErrClusterNotFound error when no cluster registered with the name
+	ErrClusterNotFound = errors.New("server cluster is not found")
+	//ErrClientType error when the client built is not the type wanted
+	ErrClientType = errors.New("client type mismatch")
 )
 
 //Options is for GRPCPool
 type Options struct {
-	Cap             int
+	Cap int
+	//Targets are the addresses to dial , written as "host:port" or "host:port;weight=N"
+	//connections and requests are spread across targets in proportion to weight
 	Targets         []string
 	ClientKeepAlive bool
 	DialTimeout     time.Duration
 	IdleTimeout     time.Duration
 	PingTimeout     time.Duration
 	ForcePermit     bool
+	//MaxStreamsPerConn limits concurrent streams on one connection
+	//connections are picked least-loaded when it is set, 0 means no limit
+	MaxStreamsPerConn int
+	//Picker choose connections for Get
+	//round-robin is used by default , least-refcount when MaxStreamsPerConn is set , both follow target weights
+	//a Picker set here chooses among connections of all targets
+	Picker Picker
+	//HealthCheckInterval enables background grpc.health.v1 checks on every connection
+	//failing connections are evicted and replaced , 0 means disabled
+	HealthCheckInterval time.Duration
+	//HealthCheckTimeout bounds each check , DialTimeout is used when it is 0
+	HealthCheckTimeout time.Duration
+	//HealthCheckService is the service name to check , empty for the whole server
+	HealthCheckService string
+	//MaxIdleTime closes connections without streams for longer than it , 0 means never
+	MaxIdleTime time.Duration
+	//MaxConnLifetime replaces connections older than it , they are drained after replacements are put into pool , 0 means never
+	MaxConnLifetime time.Duration
+	//MinIdle is the number of connections kept dialed by a background filler
+	//they are redialed with backoff after evictions , 0 means disabled
+	MinIdle int
+	//Autoscale enables adaptive pool size , Cap is the initial size when it is set
+	Autoscale *AutoscaleOptions
+	//LeakDetection enables debug tracking of connections not released , nil means disabled
+	LeakDetection *LeakDetectionOptions
 }
 
 //validate option if available
 func (o Options) validate() error {
 	if o.Targets == nil ||
 		o.Cap <= 0 ||
-		o.DialTimeout == 0 {
+		o.DialTimeout == 0 ||
+		o.MaxStreamsPerConn < 0 ||
+		o.HealthCheckInterval < 0 ||
+		o.HealthCheckTimeout < 0 ||
+		o.MaxIdleTime < 0 ||
+		o.MaxConnLifetime < 0 ||
+		o.MinIdle < 0 ||
+		o.MinIdle > o.Cap {
 		return ErrOptionValid
 	}
 
+	if _, err := parseTargets(o.Targets); err != nil {
+		return err
+	}
+
+	if o.Autoscale != nil {
+		if err := o.Autoscale.validate(); err != nil {
+			return err
+		}
+		if o.Cap < o.Autoscale.MinCap || o.Cap > o.Autoscale.MaxCap {
+			return ErrOptionValid
+		}
+	}
+
+	if o.LeakDetection != nil {
+		if err := o.LeakDetection.validate(); err != nil {
+			return err
+		}
+	}
+
 	if o.ClientKeepAlive && (o.PingTimeout == 0 ||
 		o.IdleTimeout == 0) {
 		return ErrOptionValid
@@ -54,17 +115,12 @@ func (o Options) validate() error {
 	return nil
 }
 
-//getTarget return a rand target from Options.Targets
-func (o Options) getTarget() string {
-	l := len(o.Targets)
-	if l == 0 {
-		return ""
-	}
-	if l == 1 {
-		return o.Targets[0]
+//healthCheckTimeout return the timeout of each health check
+func (o Options) healthCheckTimeout() time.Duration {
+	if o.HealthCheckTimeout > 0 {
+		return o.HealthCheckTimeout
 	}
-	//随机获取目标地址
-	return o.Targets[rand.Int()%l]
+	return o.DialTimeout
 }
 
 //NewOptions  return a *Options instance
@@ -13,7 +13,6 @@ func TestNewOptions(t *testing.T) {
 	}
 	assert.Equal(t, 10, opt.Cap)
 	assert.Contains(t, opt.Targets, "127.0.0.1:8899")
-	assert.Equal(t, "127.0.0.1:8899", opt.getTarget())
 	assert.Equal(t, 5*time.Second, opt.IdleTimeout)
 }
 
@@ -28,3 +27,42 @@ func TestOptionsValid(t *testing.T) {
 	err = opt.validate()
 	assert.IsType(t, err, ErrOptionValid)
 }
+
+func TestOptionsValidMaxStreamsPerConn(t *testing.T) {
+	opt, _ := NewOptions(10, []string{"127.0.0.1:8899"})
+	opt.MaxStreamsPerConn = -1
+	assert.Equal(t, ErrOptionValid, opt.validate())
+
+	opt.MaxStreamsPerConn = 100
+	assert.Nil(t, opt.validate())
+}
+
+func TestOptionsHealthCheckTimeout(t *testing.T) {
+	opt, _ := NewOptions(10, []string{"127.0.0.1:8899"})
+	assert.Equal(t, opt.DialTimeout, opt.healthCheckTimeout())
+
+	opt.HealthCheckTimeout = time.Second
+	assert.Equal(t, time.Second, opt.healthCheckTimeout())
+
+	opt.HealthCheckInterval = -1
+	assert.Equal(t, ErrOptionValid, opt.validate())
+}
+
+func TestOptionsValidConnLifetime(t *testing.T) {
+	opt, _ := NewOptions(10, []string{"127.0.0.1:8899"})
+	opt.MaxIdleTime = -1
+	assert.Equal(t, ErrOptionValid, opt.validate())
+
+	opt.MaxIdleTime = time.Minute
+	opt.MaxConnLifetime = -1
+	assert.Equal(t, ErrOptionValid, opt.validate())
+}
+
+func TestOptionsValidMinIdle(t *testing.T) {
+	opt, _ := NewOptions(10, []string{"127.0.0.1:8899"})
+	opt.MinIdle = 11
+	assert.Equal(t, ErrOptionValid, opt.validate())
+
+	opt.MinIdle = 10
+	assert.Nil(t, opt.validate())
+}
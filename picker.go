@@ -0,0 +1,119 @@
+package pool
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+//Picker choose one connection for each GRPCPool.Get
+//conns are connections of pool , it is never empty
+//with Options.MaxStreamsPerConn only connections having room are passed
+//the default Picker gets connections of the target scheduled by weight , other Pickers get connections of all targets
+//they are not locked while picking , so some may be dead or full , the chosen one is checked after Pick
+//and Get picks again when it is not available , Pick may also return nil for that
+//conns is only valid during the call and must not be retained
+//a Picker may be shared by pools , so it must be safe for concurrent use
+type Picker interface {
+	Pick(conns []*GrpcConn) *GrpcConn
+}
+
+//PickerFunc is an adapter to use an ordinary function as Picker
+type PickerFunc func(conns []*GrpcConn) *GrpcConn
+
+//Pick calls fn(conns)
+func (fn PickerFunc) Pick(conns []*GrpcConn) *GrpcConn {
+	return fn(conns)
+}
+
+//roundRobinPicker pick connections in turn
+type roundRobinPicker struct {
+	next uint64
+}
+
+//NewRoundRobinPicker return a Picker choosing connections in turn
+//it is the default Picker of GRPCPool
+func NewRoundRobinPicker() Picker {
+	return &roundRobinPicker{}
+}
+
+//Pick return the next connection
+func (rr *roundRobinPicker) Pick(conns []*GrpcConn) *GrpcConn {
+	n := atomic.AddUint64(&rr.next, 1)
+	return conns[(n-1)%uint64(len(conns))]
+}
+
+//NewRandomPicker return a Picker choosing a random connection
+func NewRandomPicker() Picker {
+	return PickerFunc(func(conns []*GrpcConn) *GrpcConn {
+		return conns[rand.Intn(len(conns))]
+	})
+}
+
+//NewLeastRefCountPicker return a Picker choosing the connection with the fewest streams
+//it is the default Picker when Options.MaxStreamsPerConn is set
+func NewLeastRefCountPicker() Picker {
+	return PickerFunc(func(conns []*GrpcConn) *GrpcConn {
+		least := conns[0]
+		for _, conn := range conns[1:] {
+			if conn.RefCount() < least.RefCount() {
+				least = conn
+			}
+		}
+		return least
+	})
+}
+
+//NewP2CPicker return a power-of-two-choices Picker
+//it samples two random connections and choose the one with fewer streams
+func NewP2CPicker() Picker {
+	return PickerFunc(func(conns []*GrpcConn) *GrpcConn {
+		l := len(conns)
+		if l == 1 {
+			return conns[0]
+		}
+		i := rand.Intn(l)
+		//j is different from i
+		j := (i + 1 + rand.Intn(l-1)) % l
+		if conns[j].RefCount() < conns[i].RefCount() {
+			return conns[j]
+		}
+		return conns[i]
+	})
+}
+
+//NewWeightedPicker return a Picker choosing connections randomly in proportion to
+//the weight of their target , targets not in weights have weight 1
+//targets with weight 0 are only picked when no others are available
+func NewWeightedPicker(weights map[string]int) Picker {
+	w := make(map[string]int, len(weights))
+	for target, weight := range weights {
+		if weight < 0 {
+			weight = 0
+		}
+		w[target] = weight
+	}
+	return PickerFunc(func(conns []*GrpcConn) *GrpcConn {
+		total := 0
+		for _, conn := range conns {
+			total += weightOf(w, conn.Target())
+		}
+		if total == 0 {
+			return conns[rand.Intn(len(conns))]
+		}
+		n := rand.Intn(total)
+		for _, conn := range conns {
+			if n -= weightOf(w, conn.Target()); n < 0 {
+				return conn
+			}
+		}
+		return conns[len(conns)-1]
+	})
+}
+
+//weightOf return the weight of target , 1 by default
+func weightOf(weights map[string]int, target string) int {
+	if weight, ok := weights[target]; ok {
+		return weight
+	}
+	return 1
+}
@@ -0,0 +1,102 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func createTestingConns(refcounts ...int64) []*GrpcConn {
+	conns := make([]*GrpcConn, 0, len(refcounts))
+	for _, rc := range refcounts {
+		conns = append(conns, &GrpcConn{refcount: rc})
+	}
+	return conns
+}
+
+func TestRoundRobinPicker(t *testing.T) {
+	conns := createTestingConns(0, 0, 0)
+	picker := NewRoundRobinPicker()
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, conns[i%3], picker.Pick(conns))
+	}
+}
+
+func TestRandomPicker(t *testing.T) {
+	conns := createTestingConns(0, 0, 0)
+	picker := NewRandomPicker()
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, conns, picker.Pick(conns))
+	}
+}
+
+func TestLeastRefCountPicker(t *testing.T) {
+	conns := createTestingConns(3, 1, 2)
+	picker := NewLeastRefCountPicker()
+	assert.Equal(t, conns[1], picker.Pick(conns))
+}
+
+func TestP2CPicker(t *testing.T) {
+	picker := NewP2CPicker()
+	conns := createTestingConns(5)
+	assert.Equal(t, conns[0], picker.Pick(conns))
+
+	//the most loaded connection never wins a choice of two
+	conns = createTestingConns(1, 9, 2)
+	for i := 0; i < 20; i++ {
+		assert.NotEqual(t, conns[1], picker.Pick(conns))
+	}
+}
+
+func TestWeightedPicker(t *testing.T) {
+	connA, _ := grpc.Dial("127.0.0.1:7001", grpc.WithInsecure())
+	connB, _ := grpc.Dial("127.0.0.1:7002", grpc.WithInsecure())
+	defer connA.Close()
+	defer connB.Close()
+	conns := []*GrpcConn{{conn: connA}, {conn: connB}}
+
+	picker := NewWeightedPicker(map[string]int{"127.0.0.1:7001": 3, "127.0.0.1:7002": 0})
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, conns[0], picker.Pick(conns))
+	}
+	assert.Equal(t, conns[1], picker.Pick(conns[1:]))
+
+	picker = NewWeightedPicker(map[string]int{"127.0.0.1:7001": 1})
+	counts := map[*GrpcConn]int{}
+	for i := 0; i < 100; i++ {
+		counts[picker.Pick(conns)]++
+	}
+	assert.True(t, counts[conns[0]] > 0)
+	assert.True(t, counts[conns[1]] > 0)
+}
+
+func TestGRPCPool_SetPicker(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(3, []string{addr})
+	opt.Picker = NewLeastRefCountPicker()
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+	_ = pool.InitConnections()
+
+	pool.conns()[0].tryUse(0)
+	pool.conns()[2].tryUse(0)
+	gConn, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, pool.conns()[1], gConn)
+
+	pool.SetPicker(PickerFunc(func(conns []*GrpcConn) *GrpcConn {
+		return conns[len(conns)-1]
+	}))
+	gConn, err = pool.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, pool.conns()[2], gConn)
+
+	//nil resets the default Picker instead of panicking in Get
+	pool.SetPicker(nil)
+	assert.IsType(t, &roundRobinPicker{}, pool.loadPicker())
+	gConn, err = pool.Get()
+	assert.Nil(t, err)
+	assert.NotNil(t, gConn)
+}
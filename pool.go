@@ -2,17 +2,41 @@ package pool
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/keepalive"
 )
 
+const (
+	//getRetryMinDelay is the first wait of GetContext after a failed attempt
+	getRetryMinDelay = 10 * time.Millisecond
+	//getRetryMaxDelay is the upper bound of GetContext retry backoff
+	getRetryMaxDelay = time.Second
+)
+
+const (
+	poolOpen int32 = iota
+	poolDraining
+	poolClosed
+)
+
+const (
+	retiredNone int32 = iota
+	retiredDraining
+	retiredClosed
+)
+
 //ConnFactoryFunc type of function to create grpc conn
 type ConnFactoryFunc func(p *GRPCPool) (*grpc.ClientConn, error)
 
+//TargetConnFactoryFunc type of function to create grpc conn to the target chosen by pool
+type TargetConnFactoryFunc func(p *GRPCPool, target string) (*grpc.ClientConn, error)
+
 //ConnCloseFunc type of function to close grpc conn
 type ConnCloseFunc func(conn *grpc.ClientConn) error
 
@@ -20,7 +44,18 @@ type ConnCloseFunc func(conn *grpc.ClientConn) error
 type GrpcConn struct {
 	conn     *grpc.ClientConn
 	pool     *GRPCPool
+	target   string
 	refcount int64
+	//retired is retiredDraining when conn is removed from pool
+	//and retiredClosed after it is closed
+	retired int32
+	doClose ConnCloseFunc
+	//createdAt is when conn is dialed
+	//lastUsed is unix nano of the last release of all its streams , it is only kept with Options.MaxIdleTime
+	createdAt time.Time
+	lastUsed  int64
+	//generation is the generation of pool settings conn is dialed with
+	generation int
 }
 
 //Conn return the *grpc.ClientConn
@@ -28,23 +63,104 @@ func (g *GrpcConn) Conn() *grpc.ClientConn {
 	return g.conn
 }
 
-//to increase num of stream on conn
-func (g *GrpcConn) use() {
-	atomic.AddInt64(&g.refcount, 1)
+//tryUse increase num of stream on conn
+//it fails when conn reaches maxStreams or is retired , 0 means no limit
+func (g *GrpcConn) tryUse(maxStreams int64) bool {
+	for {
+		n := atomic.LoadInt64(&g.refcount)
+		if maxStreams > 0 && n >= maxStreams {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&g.refcount, n, n+1) {
+			break
+		}
+	}
+	//conn may be retired after it is picked
+	if atomic.LoadInt32(&g.retired) != retiredNone {
+		g.unuse()
+		return false
+	}
+	return true
+}
+
+//unuse decrease num of stream on conn and close it when retired and idle
+//the num never goes below 0 , extra calls are ignored
+func (g *GrpcConn) unuse() {
+	for {
+		n := atomic.LoadInt64(&g.refcount)
+		if n <= 0 {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&g.refcount, n, n-1) {
+			if n == 1 {
+				g.idle()
+			}
+			return
+		}
+	}
+}
+
+//idle record the time conn becomes idle for the janitor and close it when retired
+func (g *GrpcConn) idle() {
+	if g.pool.loadSettings().options.MaxIdleTime > 0 {
+		atomic.StoreInt64(&g.lastUsed, time.Now().UnixNano())
+	}
+	if atomic.LoadInt32(&g.retired) == retiredDraining {
+		g.closeRetired()
+	}
 }
 
-//Release put conn back pool or close conn when pool is full
+//Release put conn back pool
+//a conn removed from pool is closed when its last stream is released
+//conn is shared , so each Get must be released exactly once , use GRPCPool.Acquire for an idempotent Lease
 func (g *GrpcConn) Release() {
-	if g.pool.Len() > g.pool.options.Cap {
-		_ = g.pool.connDoClose(g.conn)
-		return
+	g.release(nil)
+}
+
+//release put conn back pool and untrack the lease by leak detection
+//the oldest lease of conn is untracked when info is nil
+func (g *GrpcConn) release(info *leaseInfo) {
+	if g.pool.leaks != nil {
+		g.pool.leaks.untrack(g, info)
 	}
-	atomic.AddInt64(&g.refcount, -1)
+	g.unuse()
+	g.pool.notifyReleased()
 }
 
 //RefCount return stream count on conn
 func (g *GrpcConn) RefCount() int64 {
-	return g.refcount
+	return atomic.LoadInt64(&g.refcount)
+}
+
+//Target return the target address the conn dialed
+func (g *GrpcConn) Target() string {
+	if g.target != "" || g.conn == nil {
+		return g.target
+	}
+	return g.conn.Target()
+}
+
+//alive check connection state if available
+func (g *GrpcConn) alive() bool {
+	state := g.conn.GetState()
+	return state != connectivity.Shutdown && state != connectivity.TransientFailure
+}
+
+//retire mark the conn removed from pool
+//it is closed by doClose at once when idle , or when its last stream is released
+func (g *GrpcConn) retire(doClose ConnCloseFunc) {
+	g.doClose = doClose
+	atomic.StoreInt32(&g.retired, retiredDraining)
+	if g.RefCount() == 0 {
+		g.closeRetired()
+	}
+}
+
+//closeRetired close a retired conn only once
+func (g *GrpcConn) closeRetired() {
+	if atomic.CompareAndSwapInt32(&g.retired, retiredDraining, retiredClosed) {
+		_ = g.doClose(g.conn)
+	}
 }
 
 //Close to close the conn
@@ -53,122 +169,521 @@ func (g *GrpcConn) Close() error {
 }
 
 //GRPCPool struct of pool
-//pool struct
+//connections are kept in an immutable slice snapshot , Get reads it without lock
+//and mutations replace the snapshot with a copy while holding the lock
 type GRPCPool struct {
-	lock        sync.Mutex
+	lock sync.Mutex
+	//settings stores the *poolSettings , it is replaced with p.lock held
+	settings atomic.Value
+	//generation is increased when settings are replaced , connections of older generations are replaced
+	generation int
+
+	//connPool stores the []*GrpcConn snapshot , nil after pool closed
+	connPool    atomic.Value
+	state       int32
+	cap         int32
+	dialing     []*dialCall
+	pending     int32
+	picker      atomic.Value
+	targets     atomic.Value
+	connFactory TargetConnFactoryFunc
+	connDoClose ConnCloseFunc
+	//untargeted is true when connFactory ignores the target chosen by pool
+	untargeted bool
+	//initialized is set by InitConnections , connections of the filler do not count
+	initialized bool
+
+	//released stores the chan struct{} closed and renewed when a stream is released
+	released atomic.Value
+	//waiters is the number of callers queued on an exhausted pool and Drain
+	waiters int32
+	//done is closed when pool is closed to stop background goroutines
+	done chan struct{}
+	//stop is closed to stop the background loops of the current settings
+	stop chan struct{}
+	//fillSignal wakes up the background filler after evictions
+	fillSignal chan struct{}
+	//leaks tracks leases when Options.LeakDetection is set , nil otherwise
+	leaks *leakDetector
+}
+
+//poolSettings are the options and dial options of pool
+type poolSettings struct {
 	options     *Options
 	dialOptions []grpc.DialOption
+}
 
-	connPool    []*GrpcConn
-	connNext    int
-	connFactory ConnFactoryFunc
-	connDoClose ConnCloseFunc
+//pickerHolder wraps Picker to be stored in atomic.Value with one concrete type
+type pickerHolder struct {
+	Picker
+	//scheduled is true for the default Picker , which only chooses among connections of the target scheduled by weight
+	scheduled bool
+}
+
+//candidatesPool reuses candidate buffers of Get
+var candidatesPool = sync.Pool{
+	New: func() interface{} {
+		return new([]*GrpcConn)
+	},
 }
 
 //SetConnFactory set factory func of create conn
+//fn chooses target itself , so connections may not be balanced across targets
+//use SetTargetConnFactory to dial the target chosen by pool
 func (p *GRPCPool) SetConnFactory(fn ConnFactoryFunc) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.connFactory = func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		return fn(p)
+	}
+	p.untargeted = true
+}
+
+//SetTargetConnFactory set factory func of create conn to a given target
+func (p *GRPCPool) SetTargetConnFactory(fn TargetConnFactoryFunc) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.connFactory = fn
+	p.untargeted = false
+}
+
+//SetPicker set the strategy of choosing connections in Get
+//picker chooses among all connections having room , so target weights only spread connections , not requests
+//the default Picker of Options is used when picker is nil , it spreads requests across targets by weight
+func (p *GRPCPool) SetPicker(picker Picker) {
+	if picker == nil {
+		p.picker.Store(pickerHolder{Picker: defaultPicker(p.loadSettings().options), scheduled: true})
+		return
+	}
+	p.picker.Store(pickerHolder{Picker: picker})
 }
 
 //SetDoConnClose set func of close conn
 func (p *GRPCPool) SetDoConnClose(fn ConnCloseFunc) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	p.connDoClose = fn
 }
 
 //GetOptions return grpc pool options
 func (p *GRPCPool) GetOptions() Options {
-	return *(p.options)
+	opt := *(p.loadSettings().options)
+	opt.Cap = p.Cap()
+	opt.Targets = p.loadTargets().raw
+	return opt
 }
 
 //GetDialOptions return grpc pool dial options
 func (p *GRPCPool) GetDialOptions() []grpc.DialOption {
-	return p.dialOptions
+	return p.loadSettings().dialOptions
+}
+
+//loadSettings return the current settings of pool
+func (p *GRPCPool) loadSettings() *poolSettings {
+	return p.settings.Load().(*poolSettings)
 }
 
 //Len return conn count
 func (p *GRPCPool) Len() int {
-	return len(p.connPool)
+	return len(p.conns())
 }
 
 //Cap return conn cap
 func (p *GRPCPool) Cap() int {
-	return p.options.Cap
+	return int(atomic.LoadInt32(&p.cap))
+}
+
+//conns return the current connections snapshot , it must not be modified
+func (p *GRPCPool) conns() []*GrpcConn {
+	conns, _ := p.connPool.Load().([]*GrpcConn)
+	return conns
+}
+
+//storeConns replace the connections snapshot
+//must be called with p.lock held
+func (p *GRPCPool) storeConns(conns []*GrpcConn) {
+	p.connPool.Store(conns)
+}
+
+//isClosed return if the pool stops handing out connections
+//it is true when pool is draining or closed
+func (p *GRPCPool) isClosed() bool {
+	return atomic.LoadInt32(&p.state) != poolOpen
+}
+
+//loadPicker return the current Picker
+func (p *GRPCPool) loadPicker() Picker {
+	return p.picker.Load().(pickerHolder).Picker
+}
+
+//Resize change the cap of pool at runtime
+//when shrinking , the least loaded surplus connections are removed from pool
+//and closed after their streams are released
+//when growing , new connections are dialed in background
+func (p *GRPCPool) Resize(n int) error {
+	if n <= 0 {
+		return ErrOptionValid
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.isClosed() {
+		return ErrPoolClosed
+	}
+	atomic.StoreInt32(&p.cap, int32(n))
+
+	if surplus := p.Len() - n; surplus > 0 {
+		conns := make([]*GrpcConn, p.Len())
+		copy(conns, p.conns())
+		sort.Slice(conns, func(i, j int) bool {
+			return conns[i].RefCount() < conns[j].RefCount()
+		})
+		for _, conn := range conns[:surplus] {
+			p.evict(conn)
+		}
+	}
+	p.refill(n - p.Len())
+	return nil
 }
 
 //InitConnections to create connections
+//connections are dialed concurrently until pool is full , the pool is closed if any dial fails
+//connections already dialed by the background filler are kept and counted
+//it returns ErrPoolInitialized when it has been called before
 func (p *GRPCPool) InitConnections() error {
-
-	poolSize := p.Len()
-	if poolSize > 0 {
+	p.lock.Lock()
+	if p.isClosed() {
+		p.lock.Unlock()
+		return ErrPoolClosed
+	}
+	if p.initialized {
+		p.lock.Unlock()
 		return ErrPoolInitialized
 	}
-	opt := p.options
-	//init and put connections into channel
-	for i := 0; i < opt.Cap; i++ {
-		conn, err := p.connFactory(p)
-		if err != nil {
-			p.Close()
-			return err
-		}
-		p.connPool = append(p.connPool, &GrpcConn{conn: conn})
+	p.initialized = true
+	calls := make([]*dialCall, 0, p.Cap())
+	for p.Len()+len(p.dialing) < p.Cap() {
+		calls = append(calls, p.startDial())
+	}
+	p.lock.Unlock()
+
+	if err := waitDials(calls); err != nil {
+		p.Close()
+		return err
 	}
 	return nil
 }
 
 //Get to get one grpc.ConnClient
+//the connection is chosen by the pool Picker from alive connections
+//when no connection is available it waits for a pending dial
+//it returns ErrPoolExhausted when every connection reaches Options.MaxStreamsPerConn
+//with Options.LeakDetection the acquisition is tracked until GrpcConn.Release
 func (p *GRPCPool) Get() (conn *GrpcConn, err error) {
-	//check pool if closed
-	if p.connPool == nil {
-		return nil, ErrPoolClosed
+	conn, err = p.get(context.Background())
+	if err == nil && p.leaks != nil {
+		p.leaks.track(conn, false)
 	}
-	p.lock.Lock()
-	defer p.lock.Unlock()
+	return conn, err
+}
 
-	retries := 0
+//get pick a connection or wait for a pending dial until ctx is done
+func (p *GRPCPool) get(ctx context.Context) (*GrpcConn, error) {
 	for {
-		//when the number of connections is not reached the cap
-		//create new connection
-		if len(p.connPool) < p.options.Cap {
-			var gconn *grpc.ClientConn
-			gconn, err = p.connFactory(p)
-			if err != nil {
-				return nil, err
+		conn, call, err := p.tryGet()
+		if conn != nil || err != nil {
+			return conn, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-call.done:
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+	}
+}
+
+//tryGet pick an available connection
+//the lock is only taken when the pool is closed , growing , or no connection is picked
+func (p *GRPCPool) tryGet() (*GrpcConn, *dialCall, error) {
+	if !p.isClosed() {
+		conns := p.conns()
+		if len(conns)+int(atomic.LoadInt32(&p.pending)) >= p.Cap() {
+			if conn := p.pick(conns); conn != nil {
+				return conn, nil, nil
 			}
-			conn = &GrpcConn{conn: gconn, pool: p, refcount: 1}
-			p.connPool = append(p.connPool, conn)
-			p.connNext = len(p.connPool)
-			return
 		}
+	}
+	return p.tryGetLocked()
+}
+
+//tryGetLocked remove dead connections , grow the pool in background and pick a connection
+//it returns a pending dial to wait for when no connection is available
+//so concurrent callers share one dial instead of dialing again
+func (p *GRPCPool) tryGetLocked() (*GrpcConn, *dialCall, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 
-		//adjust slice range to avoid array range out
-		if p.connNext >= p.options.Cap {
-			p.connNext = 0
+	//check pool if closed
+	if p.isClosed() {
+		return nil, nil, ErrPoolClosed
+	}
+	p.removeDead()
+
+	//when the number of connections is not reached the cap
+	//create new connection
+	var call *dialCall
+	if p.Len()+len(p.dialing) < p.Cap() {
+		call = p.startDial()
+	}
+
+	//picking may fail by concurrent callers of the lock-free path , so try more times
+	for i := 0; i <= p.Len(); i++ {
+		if conn := p.pick(p.conns()); conn != nil {
+			return conn, nil, nil
 		}
+	}
 
-		conn = p.connPool[p.connNext]
-		//check connection if alive
-		if conn.Conn().GetState() != connectivity.Shutdown && conn.Conn().GetState() != connectivity.TransientFailure {
-			conn.use()
-			p.connNext++
-			return
+	if call == nil && len(p.dialing) > 0 {
+		call = p.dialing[0]
+	}
+	if call == nil {
+		return nil, nil, ErrPoolExhausted
+	}
+	return nil, call, nil
+}
+
+//pick choose a connection of the snapshot conns by Picker and add a stream on it without lock
+//with multiple targets and the default Picker , targets are chosen by weight and Picker chooses among connections of the target
+//it returns nil when no connection has room or the picked one is not available
+func (p *GRPCPool) pick(conns []*GrpcConn) *GrpcConn {
+	if len(conns) == 0 {
+		return nil
+	}
+	maxStreams := int64(p.loadSettings().options.MaxStreamsPerConn)
+	picker := p.picker.Load().(pickerHolder)
+	var targets *targetSet
+	if picker.scheduled {
+		if targets = p.loadTargets(); len(targets.targets) <= 1 {
+			targets = nil
 		}
-		//if not available remove connection from pool
-		p.connPool[p.connNext] = nil
-		if p.connNext == p.Cap()-1 {
-			p.connPool = p.connPool[:p.connNext]
-		} else {
-			p.connPool = append(p.connPool[:p.connNext], p.connPool[p.connNext+1:]...)
+	}
+
+	var conn *GrpcConn
+	if maxStreams == 0 && targets == nil {
+		conn = picker.Pick(conns)
+	} else {
+		buf := candidatesPool.Get().(*[]*GrpcConn)
+		conn, *buf = p.pickBalanced(conns, targets, maxStreams, picker.Picker, (*buf)[:0])
+		candidatesPool.Put(buf)
+	}
+
+	if conn == nil || !conn.alive() || !conn.tryUse(maxStreams) {
+		return nil
+	}
+	return conn
+}
+
+//removeDead retire dead connections and remove them from pool
+//must be called with p.lock held
+func (p *GRPCPool) removeDead() {
+	conns := p.conns()
+	alive := make([]*GrpcConn, 0, len(conns))
+	for _, conn := range conns {
+		if conn.alive() {
+			alive = append(alive, conn)
+			continue
 		}
+		conn.retire(p.connDoClose)
+	}
+	if len(alive) < len(conns) {
+		p.storeConns(alive)
+		p.signalFill()
+	}
+}
 
-		retries++
-		if retries > 10 {
-			break
+//addConn put a new connection into pool
+//must be called with p.lock held
+func (p *GRPCPool) addConn(conn *GrpcConn) {
+	conns := p.conns()
+	next := make([]*GrpcConn, len(conns), len(conns)+1)
+	copy(next, conns)
+	p.storeConns(append(next, conn))
+}
+
+//contains report whether conn is in pool
+func (p *GRPCPool) contains(conn *GrpcConn) bool {
+	for _, c := range p.conns() {
+		if c == conn {
+			return true
 		}
 	}
-	return nil, ErrConnConnect
+	return false
+}
+
+//evict remove conn from pool and close it after its streams are released
+//it returns false when conn is not in pool
+//must be called with p.lock held
+func (p *GRPCPool) evict(conn *GrpcConn) bool {
+	conns := p.conns()
+	for i, c := range conns {
+		if c != conn {
+			continue
+		}
+		next := make([]*GrpcConn, 0, len(conns)-1)
+		next = append(next, conns[:i]...)
+		p.storeConns(append(next, conns[i+1:]...))
+		conn.retire(p.connDoClose)
+		p.signalFill()
+		return true
+	}
+	return false
+}
+
+//wrapConn return a new *GrpcConn of pool dialed to target
+func (p *GRPCPool) wrapConn(conn *grpc.ClientConn, target string, generation int) *GrpcConn {
+	now := time.Now()
+	return &GrpcConn{conn: conn, pool: p, target: target, createdAt: now, lastUsed: now.UnixNano(), generation: generation}
+}
+
+//GetContext to get one *GrpcConn like Get, but blocks until a healthy connection
+//is available or a new one can be dialed
+//when the pool is exhausted the caller is queued until a connection is released or added
+//it returns ctx.Err() when ctx is canceled or its deadline exceeded before that
+func (p *GRPCPool) GetContext(ctx context.Context) (*GrpcConn, error) {
+	conn, err := p.getContext(ctx)
+	if err == nil && p.leaks != nil {
+		p.leaks.track(conn, false)
+	}
+	return conn, err
+}
+
+//getContext wait for a connection until ctx is done
+//a caller is only counted as waiter after the pool is exhausted , so releases do not wake anyone otherwise
+func (p *GRPCPool) getContext(ctx context.Context) (*GrpcConn, error) {
+	queued := false
+	delay := getRetryMinDelay
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var released <-chan struct{}
+		if queued {
+			released = p.releasedChan()
+		}
+		conn, err := p.get(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		switch err {
+		case ErrPoolClosed:
+			return nil, err
+		case ErrPoolExhausted:
+			if !queued {
+				//waiters must be counted before the next attempt , so no release is missed
+				queued = true
+				atomic.AddInt32(&p.waiters, 1)
+				defer atomic.AddInt32(&p.waiters, -1)
+				continue
+			}
+			//wait for a released stream
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-released:
+			}
+			continue
+		}
+		//wait and retry with exponential backoff
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if delay *= 2; delay > getRetryMaxDelay {
+			delay = getRetryMaxDelay
+		}
+	}
+}
+
+//releasedChan return a channel closed on next stream release
+func (p *GRPCPool) releasedChan() <-chan struct{} {
+	return p.released.Load().(chan struct{})
+}
+
+//notifyReleased wake up all callers waiting in GetContext or Drain
+func (p *GRPCPool) notifyReleased() {
+	if atomic.LoadInt32(&p.waiters) == 0 {
+		return
+	}
+	p.lock.Lock()
+	p.broadcast()
+	p.lock.Unlock()
+}
+
+//broadcast wake up all waiters
+//must be called with p.lock held
+func (p *GRPCPool) broadcast() {
+	released := p.released.Load().(chan struct{})
+	p.released.Store(make(chan struct{}))
+	close(released)
+}
+
+//InUse return the number of streams on pooled connections
+func (p *GRPCPool) InUse() int64 {
+	var n int64
+	for _, conn := range p.conns() {
+		n += conn.RefCount()
+	}
+	return n
+}
+
+//Drain stop handing out connections , Get returns ErrPoolClosed after it is called
+//then wait until all streams on pooled connections are released
+//it returns ctx.Err() when ctx is done before that , connections are not closed by Drain
+func (p *GRPCPool) Drain(ctx context.Context) error {
+	//waiters must be counted before checking streams , so no release is missed
+	atomic.AddInt32(&p.waiters, 1)
+	defer atomic.AddInt32(&p.waiters, -1)
+
+	p.startDrain()
+
+	for {
+		released := p.releasedChan()
+		if p.InUse() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+//startDrain stop handing out connections
+func (p *GRPCPool) startDrain() {
+	p.lock.Lock()
+	atomic.CompareAndSwapInt32(&p.state, poolOpen, poolDraining)
+	//wake up waiters to see the pool draining
+	p.broadcast()
+	p.lock.Unlock()
+}
+
+//Shutdown drain the pool gracefully then close it
+//connections are closed even if ctx is done before all streams are released
+//and the error of Drain is returned
+func (p *GRPCPool) Shutdown(ctx context.Context) error {
+	err := p.Drain(ctx)
+	p.Close()
+	return err
 }
 
 // Close to close grpc poll
+//connections are closed at once even if they have streams , use Shutdown to close gracefully
 func (p *GRPCPool) Close() {
 
 	p.lock.Lock()
@@ -177,30 +692,44 @@ func (p *GRPCPool) Close() {
 	p.connFactory = nil
 	doClose := p.connDoClose
 	p.connDoClose = nil
-	p.options = nil
-	p.dialOptions = nil
-	p.connNext = 0
+	//wake up waiters to see the pool closed
+	p.broadcast()
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+		close(p.stop)
+	}
 
-	if p.connPool == nil {
+	if atomic.LoadInt32(&p.state) == poolClosed {
 		return
 	}
 	//to close all connections
-	for _, GrpcConn := range p.connPool {
+	for _, GrpcConn := range p.conns() {
 		_ = doClose(GrpcConn.conn)
 	}
 	//set pool nil
-	p.connPool = nil
+	p.storeConns(nil)
+	atomic.StoreInt32(&p.state, poolClosed)
 	return
 }
 
-//defaultFactoryCreateConn function to create grpc connections
-func defaultFactoryCreateConn() ConnFactoryFunc {
-	return func(p *GRPCPool) (*grpc.ClientConn, error) {
-		opt := p.options
-		dialOptions := p.dialOptions
+//defaultPicker return round-robin Picker
+//or least-refcount Picker when streams per connection is limited
+func defaultPicker(opt *Options) Picker {
+	if opt.MaxStreamsPerConn > 0 {
+		return NewLeastRefCountPicker()
+	}
+	return NewRoundRobinPicker()
+}
+
+//defaultTargetFactoryCreateConn function to create grpc connections to the given target
+func defaultTargetFactoryCreateConn() TargetConnFactoryFunc {
+	return func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		settings := p.loadSettings()
+		opt, dialOptions := settings.options, settings.dialOptions
 		ctx, cancel := context.WithTimeout(context.Background(), opt.DialTimeout)
 		defer cancel()
-		target := opt.getTarget()
 		if target == "" {
 			return nil, ErrTargetEmpty
 		}
@@ -220,6 +749,33 @@ func NewGRPCPool(opt *Options, dialOptions ...grpc.DialOption) (*GRPCPool, error
 	if err := opt.validate(); err != nil {
 		return nil, err
 	}
+
+	//pool
+	pool := &GRPCPool{}
+	pool.settings.Store(newPoolSettings(opt, dialOptions))
+	pool.cap = int32(opt.Cap)
+	pool.connFactory = defaultTargetFactoryCreateConn()
+	pool.connDoClose = defaultCloseConn()
+	pool.storeConns([]*GrpcConn{})
+	targets, _ := parseTargets(opt.Targets)
+	pool.storeTargets(opt.Targets, targets)
+	pool.SetPicker(opt.Picker)
+	pool.released.Store(make(chan struct{}))
+	pool.done = make(chan struct{})
+	pool.fillSignal = make(chan struct{}, 1)
+
+	pool.startLoops(opt)
+	if opt.LeakDetection != nil {
+		pool.leaks = newLeakDetector(*opt.LeakDetection)
+		go pool.leakLoop(opt.LeakDetection.interval())
+	}
+
+	return pool, nil
+}
+
+//newPoolSettings return the settings of opt with the keepalive dial option
+func newPoolSettings(opt *Options, dialOptions []grpc.DialOption) *poolSettings {
+	dialOptions = append([]grpc.DialOption(nil), dialOptions...)
 	if opt.ClientKeepAlive {
 		//keepalive config
 		kacp := keepalive.ClientParameters{
@@ -230,15 +786,53 @@ func NewGRPCPool(opt *Options, dialOptions ...grpc.DialOption) (*GRPCPool, error
 		keepaliveOption := grpc.WithKeepaliveParams(kacp)
 		dialOptions = append(dialOptions, keepaliveOption)
 	}
+	return &poolSettings{options: opt, dialOptions: dialOptions}
+}
 
-	//pool
-	pool := &GRPCPool{}
-	pool.options = opt
-	pool.dialOptions = dialOptions
-	pool.connFactory = defaultFactoryCreateConn()
-	pool.connDoClose = defaultCloseConn()
-	pool.connPool = make([]*GrpcConn, 0, opt.Cap)
-	pool.connNext = 0
+//startLoops start the background loops of opt , they run until p.stop is closed
+//leak detection is not restarted , it runs until pool is closed
+func (p *GRPCPool) startLoops(opt *Options) {
+	p.stop = make(chan struct{})
+	if opt.HealthCheckInterval > 0 {
+		go p.healthCheckLoop(p.stop, opt.HealthCheckInterval, opt.healthCheckTimeout(), opt.HealthCheckService)
+	}
+	if opt.MaxIdleTime > 0 || opt.MaxConnLifetime > 0 {
+		go p.janitorLoop(p.stop, opt.MaxIdleTime, opt.MaxConnLifetime, opt.MinIdle)
+	}
+	if opt.MinIdle > 0 {
+		go p.fillLoop(p.stop, opt.MinIdle)
+	}
+	if opt.Autoscale != nil {
+		go p.autoscaleLoop(p.stop, *opt.Autoscale)
+	}
+}
 
-	return pool, nil
+//reconfigure replace options and dial options of pool at runtime
+//targets , cap , picker and leak detection of opt are not applied , use UpdateTargets , Resize and SetPicker for them
+//background loops are restarted with opt and every connection is replaced like UpdateTargets does ,
+//so Get keeps serving by old connections until their replacements dialed with the new settings are put into pool
+func (p *GRPCPool) reconfigure(opt Options, dialOptions []grpc.DialOption) error {
+	if err := opt.validate(); err != nil {
+		return err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.isClosed() {
+		return ErrPoolClosed
+	}
+	old := p.loadSettings().options
+	opt.Cap = old.Cap
+	opt.Targets = old.Targets
+	opt.Picker = old.Picker
+	opt.LeakDetection = old.LeakDetection
+	p.settings.Store(newPoolSettings(&opt, dialOptions))
+	p.generation++
+	if p.picker.Load().(pickerHolder).scheduled {
+		p.SetPicker(nil)
+	}
+
+	close(p.stop)
+	p.startLoops(&opt)
+	p.rebalance()
+	return nil
 }
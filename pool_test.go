@@ -1,7 +1,11 @@
 package pool
 
 import (
+	"context"
+	"errors"
+	"net"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,8 +19,8 @@ func TestFunctions(t *testing.T) {
 	opt, _ := NewOptions(10, []string{"127.0.0.1:9999"})
 	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
 
-	createFn := defaultFactoryCreateConn()
-	conn, err := createFn(pool)
+	createFn := defaultTargetFactoryCreateConn()
+	conn, err := createFn(pool, "127.0.0.1:9999")
 	assert.Nil(t, err)
 	assert.EqualValues(t, connectivity.Idle, conn.GetState())
 
@@ -44,6 +48,19 @@ func TestNewGRPCPool(t *testing.T) {
 	assert.Equal(t, 0, pool.Len())
 }
 
+//startTestingServer run an in-process grpc server and return its address
+func startTestingServer(t testing.TB) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	return lis.Addr().String(), server.Stop
+}
+
 func CreateTestingGrpcPool() *GRPCPool {
 	opt, _ := NewOptions(10, []string{"127.0.0.1:8899"})
 	pool, _ := NewGRPCPool(opt)
@@ -99,32 +116,337 @@ func TestGRPCPool_InitConnections(t *testing.T) {
 
 func TestGRPCPool_Get(t *testing.T) {
 
-	pool := CreateFakeGrpcPool()
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(10, []string{addr})
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
 	_ = pool.InitConnections()
 	wg := sync.WaitGroup{}
-	counts := [3]int{0, 0, 0}
 	for i := 0; i < 15; i++ {
 		wg.Add(1)
 		go func(i int) {
-			gConn, err := pool.Get()
+			_, err := pool.Get()
 			assert.Nil(t, err)
-			counts[int(gConn.RefCount())]++
 			wg.Done()
 		}(i)
 	}
 	wg.Wait()
-	assert.EqualValues(t, 5, pool.connNext)
-	assert.EqualValues(t, 10, counts[1])
+	assert.EqualValues(t, 15, pool.loadPicker().(*roundRobinPicker).next)
+	counts := [3]int{0, 0, 0}
+	for _, gConn := range pool.conns() {
+		counts[int(gConn.RefCount())]++
+	}
+	assert.EqualValues(t, 5, counts[1])
 	assert.EqualValues(t, 5, counts[2])
 }
 
+func TestGRPCPool_GetContext(t *testing.T) {
+	pool := CreateFakeGrpcPool()
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	gConn, err := pool.GetContext(ctx)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, gConn.RefCount())
+
+	//fail twice then dial successfully
+	var failed int32
+	dialErr := errors.New("dial failed")
+	pool = CreateFakeGrpcPool()
+	defer pool.Close()
+	pool.SetTargetConnFactory(func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		if atomic.AddInt32(&failed, 1) <= 2 {
+			return nil, dialErr
+		}
+		return defaultTargetFactoryCreateConn()(p, target)
+	})
+	_, err = pool.Get()
+	assert.Equal(t, dialErr, err)
+	gConn, err = pool.GetContext(ctx)
+	assert.Nil(t, err)
+	assert.NotNil(t, gConn)
+	assert.True(t, atomic.LoadInt32(&failed) > 2)
+}
+
+func TestGRPCPool_GetContextDeadline(t *testing.T) {
+	pool := CreateFakeGrpcPool()
+	defer pool.Close()
+	pool.SetConnFactory(func(p *GRPCPool) (*grpc.ClientConn, error) {
+		return nil, errors.New("dial failed")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := pool.GetContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.GetContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	pool.Close()
+	_, err = pool.GetContext(context.Background())
+	assert.Equal(t, ErrPoolClosed, err)
+}
+
+func TestGRPCPool_GetMaxStreamsPerConn(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{addr})
+	opt.MaxStreamsPerConn = 2
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	conns := make([]*GrpcConn, 0, 4)
+	for i := 0; i < 4; i++ {
+		gConn, err := pool.Get()
+		assert.Nil(t, err)
+		conns = append(conns, gConn)
+	}
+	assert.Equal(t, 2, pool.Len())
+	assert.EqualValues(t, 2, pool.conns()[0].RefCount())
+	assert.EqualValues(t, 2, pool.conns()[1].RefCount())
+
+	_, err = pool.Get()
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	//least loaded connection is picked
+	pool.conns()[1].Release()
+	gConn, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, pool.conns()[1], gConn)
+
+	//caller is queued until a stream is released
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		conns[0].Release()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	gConn, err = pool.GetContext(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, conns[0], gConn)
+	assert.EqualValues(t, 2, gConn.RefCount())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = pool.GetContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestGRPCPool_Drain(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	opt.MaxStreamsPerConn = 2
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+
+	c1, _ := pool.Get()
+	c2, _ := pool.Get()
+	assert.EqualValues(t, 2, pool.InUse())
+
+	//queued caller is woken up by Drain
+	waitErr := make(chan error)
+	go func() {
+		_, err := pool.GetContext(context.Background())
+		waitErr <- err
+	}()
+
+	drained := make(chan error)
+	go func() {
+		drained <- pool.Drain(context.Background())
+	}()
+	assert.Equal(t, ErrPoolClosed, <-waitErr)
+
+	_, err := pool.Get()
+	assert.Equal(t, ErrPoolClosed, err)
+
+	c1.Release()
+	select {
+	case <-drained:
+		t.Fatal("drained with streams in use")
+	case <-time.After(20 * time.Millisecond):
+	}
+	c2.Release()
+	assert.Nil(t, <-drained)
+	assert.NotEqual(t, connectivity.Shutdown, c1.Conn().GetState())
+}
+
+func TestGRPCPool_Shutdown(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{addr})
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	_ = pool.InitConnections()
+
+	gConn, _ := pool.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, pool.Shutdown(ctx))
+	assert.Equal(t, connectivity.Shutdown, gConn.Conn().GetState())
+	assert.Equal(t, 0, pool.Len())
+
+	//release after shutdown is safe
+	assert.NotPanics(t, gConn.Release)
+	assert.EqualValues(t, 0, gConn.RefCount())
+
+	pool, _ = NewGRPCPool(opt, grpc.WithInsecure())
+	_ = pool.InitConnections()
+	assert.Nil(t, pool.Shutdown(context.Background()))
+	_, err := pool.Get()
+	assert.Equal(t, ErrPoolClosed, err)
+}
+
+func TestGRPCPool_Resize(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(4, []string{addr})
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+	_ = pool.InitConnections()
+
+	//hold streams on 3 connections
+	held := make([]*GrpcConn, 0, 3)
+	for i := 0; i < 3; i++ {
+		gConn, _ := pool.Get()
+		held = append(held, gConn)
+	}
+
+	assert.Equal(t, ErrOptionValid, pool.Resize(0))
+	assert.Nil(t, pool.Resize(1))
+	assert.Equal(t, 1, pool.Cap())
+	assert.Equal(t, 1, pool.GetOptions().Cap)
+	assert.Equal(t, 1, pool.Len())
+
+	//surplus connections are drained
+	closed := 0
+	for _, gConn := range held {
+		if !poolContains(pool, gConn) {
+			assert.NotEqual(t, connectivity.Shutdown, gConn.Conn().GetState())
+			gConn.Release()
+			assert.Equal(t, connectivity.Shutdown, gConn.Conn().GetState())
+			closed++
+		}
+	}
+	assert.Equal(t, 2, closed)
+
+	assert.Nil(t, pool.Resize(3))
+	assert.Eventually(t, func() bool {
+		return pool.Len() == 3
+	}, time.Second, 5*time.Millisecond)
+
+	pool.Close()
+	assert.Equal(t, ErrPoolClosed, pool.Resize(2))
+}
+
+func TestGRPCPool_GetContextWaiters(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	opt.MaxStreamsPerConn = 1
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+
+	//callers served at once are not counted as waiters
+	held, err := pool.GetContext(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&pool.waiters))
+
+	done := make(chan error, 1)
+	go func() {
+		gConn, err := pool.GetContext(context.Background())
+		if err == nil {
+			gConn.Release()
+		}
+		done <- err
+	}()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&pool.waiters) == 1
+	}, time.Second, 5*time.Millisecond)
+	held.Release()
+	assert.Nil(t, <-done)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&pool.waiters))
+}
+
+func TestGRPCPool_GetContextGrow(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(1, []string{addr})
+	opt.MaxStreamsPerConn = 1
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+	held, err := pool.Get()
+	assert.Nil(t, err)
+	defer held.Release()
+
+	//a caller queued on the exhausted pool is served by the new connection
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		gConn, err := pool.GetContext(ctx)
+		if err == nil {
+			gConn.Release()
+		}
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	assert.Nil(t, pool.Resize(2))
+	assert.Nil(t, <-done)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestGRPCPool_Reconfigure(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{addr})
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer pool.Close()
+	gate := make(chan struct{})
+	pool.SetTargetConnFactory(func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		<-gate
+		return defaultTargetFactoryCreateConn()(p, target)
+	})
+
+	//a conn dialed before settings are replaced is replaced after it is put into pool
+	pool.lock.Lock()
+	call := pool.startDial()
+	pool.lock.Unlock()
+	newOpt := *opt
+	newOpt.DialTimeout = time.Second
+	newOpt.MaxStreamsPerConn = 1
+	assert.Nil(t, pool.reconfigure(newOpt, []grpc.DialOption{grpc.WithInsecure()}))
+	assert.Equal(t, time.Second, pool.GetOptions().DialTimeout)
+	assert.Equal(t, 1, pool.GetOptions().MaxStreamsPerConn)
+	close(gate)
+	<-call.done
+	assert.Nil(t, call.err)
+	assert.Equal(t, 0, call.conn.generation)
+	assert.Eventually(t, func() bool {
+		conns := pool.conns()
+		return len(conns) == 1 && conns[0].generation == 1
+	}, time.Second, 5*time.Millisecond)
+
+	newOpt.DialTimeout = 0
+	assert.Equal(t, ErrOptionValid, pool.reconfigure(newOpt, nil))
+	pool.Close()
+	assert.Equal(t, ErrPoolClosed, pool.reconfigure(*opt, nil))
+}
+
 func TestGRPCPool_Close(t *testing.T) {
 	pool := CreateFakeGrpcPool()
 	_ = pool.InitConnections()
 
 	pool.Close()
 
-	assert.Nil(t, pool.connPool)
+	assert.Nil(t, pool.conns())
 	assert.Nil(t, pool.connFactory)
 	assert.Nil(t, pool.connDoClose)
 }
@@ -155,3 +477,81 @@ func TestGrpcConn_Close(t *testing.T) {
 	err := gConn.Close()
 	assert.Nil(t, err)
 }
+
+//mutexPool is the Get of a mutex guarded slice before connections snapshot , it is kept to compare in benchmarks
+type mutexPool struct {
+	lock  sync.Mutex
+	conns []*GrpcConn
+	next  int
+}
+
+func (m *mutexPool) get() (*GrpcConn, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for range m.conns {
+		if m.next >= len(m.conns) {
+			m.next = 0
+		}
+		conn := m.conns[m.next]
+		m.next++
+		if conn.alive() {
+			atomic.AddInt64(&conn.refcount, 1)
+			return conn, nil
+		}
+	}
+	return nil, ErrConnConnect
+}
+
+func (m *mutexPool) release(conn *GrpcConn) {
+	atomic.AddInt64(&conn.refcount, -1)
+}
+
+//BenchmarkGRPCPool_GetParallel compares Get of the connections snapshot with the mutex guarded Get
+//run it with -cpu 1,4,8 to see how they scale
+func BenchmarkGRPCPool_GetParallel(b *testing.B) {
+	addr, stop := startTestingServer(b)
+	defer stop()
+	opt, _ := NewOptions(10, []string{addr})
+	pool, _ := NewGRPCPool(opt, grpc.WithInsecure())
+	defer pool.Close()
+	_ = pool.InitConnections()
+
+	b.Run("mutex", func(b *testing.B) {
+		m := &mutexPool{conns: pool.conns()}
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				gConn, err := m.get()
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				m.release(gConn)
+			}
+		})
+	})
+	b.Run("snapshot", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				gConn, err := pool.Get()
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				gConn.Release()
+			}
+		})
+	})
+	b.Run("acquire", func(b *testing.B) {
+		ctx := context.Background()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				lease, err := pool.Acquire(ctx)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				lease.Release()
+			}
+		})
+	})
+}
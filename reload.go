@@ -0,0 +1,160 @@
+package pool
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"google.golang.org/grpc"
+)
+
+//Reload apply cfg to the Service Center
+//new clusters are registered , removed clusters are unregistered and their pools drained and closed
+//changed clusters are updated in place , so *ServerCluster and its Pool got before keep working :
+//targets , cap , picker and services are applied to the running pool ,
+//other settings replace every connection of the pool , old connections are drained after their replacements are put into pool
+//removed pools are drained until ctx is done and then closed , ctx.Err() is returned if they are not drained in time
+//nothing is applied when any cluster of cfg fails to build
+//errors of applying to running pools do not stop other clusters , the first one is returned
+func (sc *ServiceCenter) Reload(ctx context.Context, cfg *ServiceCenterConfig) error {
+	sc.reloadLock.Lock()
+	defer sc.reloadLock.Unlock()
+
+	names := make([]string, 0, len(cfg.Clusters))
+	for name := range cfg.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	//build every new pool and dial settings before changing anything
+	created := make(map[string]*ServerCluster)
+	dials := make(map[string][]grpc.DialOption)
+	for _, name := range names {
+		c := cfg.Clusters[name]
+		var err error
+		if server, ok := sc.Get(name); !ok {
+			var fresh *ServerCluster
+			if fresh, err = c.newServerCluster(name); err == nil {
+				created[name] = fresh
+			}
+		} else if server.needReconfigure(c) {
+			dials[name], err = c.checkedDialOptions(name)
+		}
+		if err != nil {
+			for _, s := range created {
+				s.Pool.Close()
+			}
+			return err
+		}
+	}
+
+	var firstErr error
+	for _, name := range names {
+		if fresh, ok := created[name]; ok {
+			sc.Register(fresh)
+			continue
+		}
+		server, _ := sc.Get(name)
+		dialOptions, reconfigured := dials[name]
+		if err := server.update(cfg.Clusters[name], reconfigured, dialOptions); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	var retired []*GRPCPool
+	sc.lock.Lock()
+	sc.clusters.Range(func(key, value interface{}) bool {
+		if _, ok := cfg.Clusters[key.(string)]; !ok {
+			sc.clusters.Delete(key)
+			retired = append(retired, value.(*ServerCluster).Pool)
+		}
+		return true
+	})
+	sc.lock.Unlock()
+
+	if err := shutdownPools(ctx, retired); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+//needReconfigure report whether c changes settings which need new connections
+func (server *ServerCluster) needReconfigure(c ClusterConfig) bool {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	if server.config == nil {
+		return true
+	}
+	return !reflect.DeepEqual(server.config.dialSettings(), c.dialSettings())
+}
+
+//dialSettings return c without settings applied to a running pool
+func (c ClusterConfig) dialSettings() ClusterConfig {
+	c.Targets = nil
+	c.Cap = 0
+	c.Picker = ""
+	c.Services = nil
+	return c
+}
+
+//checkedDialOptions validate options of c and return its dial options , errors are qualified with the path of cluster name
+func (c ClusterConfig) checkedDialOptions(name string) ([]grpc.DialOption, error) {
+	path := "clusters." + name
+	dialOptions, err := c.dialOptions(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.options().validate(); err != nil {
+		return nil, &ConfigError{Path: path, Err: err}
+	}
+	return dialOptions, nil
+}
+
+//update apply c to the running pool , dial settings are applied first when reconfigured is true
+//errors are qualified with the path of the setting , the other settings are still applied
+func (server *ServerCluster) update(c ClusterConfig, reconfigured bool, dialOptions []grpc.DialOption) error {
+	server.lock.Lock()
+	old := server.config
+	server.config = &c
+	server.clientBuilder = c.serverBuilders()
+	server.lock.Unlock()
+
+	var firstErr error
+	path := "clusters." + server.Name
+	if reconfigured {
+		if err := server.Pool.reconfigure(c.options(), dialOptions); err != nil {
+			firstErr = &ConfigError{Path: path, Err: err}
+		}
+	}
+	if old == nil || !reflect.DeepEqual(old.Targets, c.Targets) {
+		if err := server.Pool.UpdateTargets(c.Targets); err != nil && firstErr == nil {
+			firstErr = &ConfigError{Path: path + ".targets", Err: err}
+		}
+	}
+	if old == nil || old.Cap != c.Cap {
+		if err := server.Pool.Resize(c.Cap); err != nil && firstErr == nil {
+			firstErr = &ConfigError{Path: path + ".cap", Err: err}
+		}
+	}
+	if old == nil || old.Picker != c.Picker {
+		server.Pool.SetPicker(c.options().Picker)
+	}
+	return firstErr
+}
+
+//shutdownPools shutdown pools concurrently and return the first error
+func shutdownPools(ctx context.Context, pools []*GRPCPool) error {
+	errs := make(chan error, len(pools))
+	for _, p := range pools {
+		go func(p *GRPCPool) {
+			errs <- p.Shutdown(ctx)
+		}(p)
+	}
+	var err error
+	for range pools {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
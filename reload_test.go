@@ -0,0 +1,236 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseTestingConfig(t *testing.T, config string) *ServiceCenterConfig {
+	cfg, err := ParseServiceCenterConfig(strings.NewReader(config))
+	assert.Nil(t, err)
+	return cfg
+}
+
+func TestServiceCenter_Reload(t *testing.T) {
+	addr1, stop1 := startTestingServer(t)
+	defer stop1()
+	addr2, stop2 := startTestingServer(t)
+	defer stop2()
+
+	sc, err := NewServiceCenterFromConfig(parseTestingConfig(t, `
+defaults: {cap: 2, services: {default: testing}}
+clusters:
+  server1: {targets: ["`+addr1+`"]}
+  server2: {targets: ["`+addr1+`"]}
+`))
+	assert.Nil(t, err)
+	s1 := sc.UnsafeGet("server1")
+	pool1 := s1.Pool
+	defer pool1.Close()
+	pool2 := sc.UnsafeGet("server2").Pool
+
+	//a stream of removed cluster is waited
+	conn2, err := pool2.Get()
+	assert.Nil(t, err)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		conn2.Release()
+	}()
+
+	err = sc.Reload(context.Background(), parseTestingConfig(t, `
+defaults: {cap: 2, services: {default: testing}}
+clusters:
+  server1: {targets: ["`+addr2+`"], cap: 3, picker: random, services: {other: testing}}
+  server3: {targets: ["`+addr2+`"]}
+`))
+	assert.Nil(t, err)
+
+	//server1 is updated in place
+	assert.True(t, s1 == sc.UnsafeGet("server1"))
+	assert.True(t, pool1 == s1.Pool)
+	assert.Equal(t, []Target{{addr2, 1}}, pool1.Targets())
+	assert.Equal(t, 3, pool1.Cap())
+	client, release, err := s1.GetServerClient("other")
+	assert.Nil(t, err)
+	assert.NotNil(t, client)
+	release()
+
+	//server2 is drained and closed
+	_, ok := sc.Get("server2")
+	assert.False(t, ok)
+	assert.EqualValues(t, 0, conn2.RefCount())
+	_, err = pool2.Get()
+	assert.Equal(t, ErrPoolClosed, err)
+
+	s3, ok := sc.Get("server3")
+	assert.True(t, ok)
+	defer s3.Pool.Close()
+	conn, err := s3.GetClient()
+	assert.Nil(t, err)
+	assert.Equal(t, addr2, conn.Target())
+	conn.Release()
+}
+
+func TestServiceCenter_ReloadDialSettings(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+
+	sc, err := NewServiceCenterFromConfig(parseTestingConfig(t, `
+clusters:
+  server1: {cap: 2, targets: ["`+addr+`"]}
+`))
+	assert.Nil(t, err)
+	s1 := sc.UnsafeGet("server1")
+	pool1 := s1.Pool
+	defer pool1.Close()
+	assert.Nil(t, pool1.InitConnections())
+	conn, err := s1.GetClient()
+	assert.Nil(t, err)
+
+	//dial settings replace every connection of the same pool
+	err = sc.Reload(context.Background(), parseTestingConfig(t, `
+clusters:
+  server1: {cap: 2, targets: ["`+addr+`"], dial_timeout: 1s, min_idle: 1}
+`))
+	assert.Nil(t, err)
+	assert.True(t, s1 == sc.UnsafeGet("server1"))
+	assert.True(t, pool1 == s1.Pool)
+	assert.Equal(t, time.Second, pool1.GetOptions().DialTimeout)
+	assert.Equal(t, 1, pool1.GetOptions().MinIdle)
+	assert.Eventually(t, func() bool {
+		return !pool1.contains(conn) && pool1.Len() == 2
+	}, time.Second, 5*time.Millisecond)
+	for _, c := range pool1.conns() {
+		assert.Equal(t, 1, c.generation)
+	}
+
+	//the replaced conn is closed after its stream is released
+	assert.EqualValues(t, retiredDraining, atomic.LoadInt32(&conn.retired))
+	conn.Release()
+	assert.EqualValues(t, retiredClosed, atomic.LoadInt32(&conn.retired))
+
+	conn, err = s1.GetClient()
+	assert.Nil(t, err)
+	conn.Release()
+}
+
+func TestServiceCenter_ReloadConcurrentGet(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+
+	sc, err := NewServiceCenterFromConfig(parseTestingConfig(t, `
+clusters:
+  server1: {cap: 2, targets: ["`+addr+`"]}
+`))
+	assert.Nil(t, err)
+	s1 := sc.UnsafeGet("server1")
+	defer s1.Pool.Close()
+
+	//Get keeps serving while dial settings are reloaded
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var failed int32
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				conn, err := s1.GetClientContext(context.Background())
+				if err != nil {
+					atomic.AddInt32(&failed, 1)
+					continue
+				}
+				conn.Release()
+			}
+		}()
+	}
+	for i := 1; i <= 5; i++ {
+		err = sc.Reload(context.Background(), parseTestingConfig(t, `
+clusters:
+  server1: {cap: 2, targets: ["`+addr+`"], dial_timeout: `+strconv.Itoa(i)+`s}
+`))
+		assert.Nil(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(done)
+	wg.Wait()
+	assert.EqualValues(t, 0, failed)
+	assert.Equal(t, 5*time.Second, s1.Pool.GetOptions().DialTimeout)
+}
+
+func TestServiceCenter_ReloadFailed(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+
+	//a cluster registered in code is reconfigured by configuration
+	opt, _ := NewOptions(2, []string{addr})
+	s1, _ := NewServerCluster("server1", *opt, nil)
+	sc := &ServiceCenter{}
+	sc.Register(s1)
+	pool1 := s1.Pool
+	defer pool1.Close()
+
+	err := sc.Reload(context.Background(), parseTestingConfig(t, `
+clusters:
+  server1: {cap: 2, targets: ["`+addr+`"]}
+  server2: {cap: 2, targets: ["`+addr+`"], tls: {ca_file: /nonexistent/ca.pem}}
+`))
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "clusters.server2.tls.ca_file: "), err.Error())
+	assert.True(t, pool1 == s1.Pool)
+	assert.Equal(t, 0, pool1.loadSettings().options.MinIdle)
+	_, ok := sc.Get("server2")
+	assert.False(t, ok)
+
+	err = sc.Reload(context.Background(), parseTestingConfig(t, `
+clusters:
+  server1: {cap: 2, targets: ["`+addr+`"], min_idle: 1}
+`))
+	assert.Nil(t, err)
+	assert.True(t, pool1 == s1.Pool)
+	assert.Equal(t, 1, pool1.GetOptions().MinIdle)
+	conn, err := s1.GetClient()
+	assert.Nil(t, err)
+	conn.Release()
+}
+
+func TestServiceCenter_ReloadUpdateError(t *testing.T) {
+	addr1, stop1 := startTestingServer(t)
+	defer stop1()
+	addr2, stop2 := startTestingServer(t)
+	defer stop2()
+
+	sc, err := NewServiceCenterFromConfig(parseTestingConfig(t, `
+clusters:
+  server1: {cap: 2, targets: ["`+addr1+`"]}
+  server2: {cap: 2, targets: ["`+addr1+`"]}
+`))
+	assert.Nil(t, err)
+	defer sc.UnsafeGet("server2").Pool.Close()
+	sc.UnsafeGet("server1").Pool.Close()
+
+	//errors of a running pool are returned and other clusters are still updated
+	err = sc.Reload(context.Background(), parseTestingConfig(t, `
+clusters:
+  server1: {cap: 3, targets: ["`+addr2+`"]}
+  server2: {cap: 3, targets: ["`+addr2+`"]}
+`))
+	assert.True(t, errors.Is(err, ErrPoolClosed))
+	assert.True(t, strings.HasPrefix(err.Error(), "clusters.server1.targets: "), err.Error())
+	pool2 := sc.UnsafeGet("server2").Pool
+	assert.Equal(t, 3, pool2.Cap())
+	assert.Equal(t, []Target{{addr2, 1}}, pool2.Targets())
+}
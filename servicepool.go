@@ -1,7 +1,10 @@
 package pool
 
 import (
+	"context"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"google.golang.org/grpc"
 )
@@ -9,13 +12,28 @@ import (
 //ServerBuilderFunc defined the func of builder
 type ServerBuilderFunc func(conn grpc.ClientConnInterface) interface{}
 
+//TargetsUpdatedFunc is called after targets of a cluster are updated by discovery
+//err is the error of the update , ErrClusterNotFound when the cluster is not registered
+type TargetsUpdatedFunc func(name string, targets []string, err error)
+
 //ServiceCenter service manage center
 type ServiceCenter struct {
-	clusters sync.Map
+	//lock serializes changes of clusters , reading is lock free
+	lock           sync.Mutex
+	clusters       sync.Map
+	targetsUpdated atomic.Value
+	//reloadLock serializes Reload
+	reloadLock sync.Mutex
+	//done is closed by Close to stop watching discovery
+	done     chan struct{}
+	doneOnce sync.Once
+	closed   sync.Once
 }
 
 //Register to put a server cluster into center
 func (sc *ServiceCenter) Register(server *ServerCluster) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
 	sc.clusters.Store(server.Name, server)
 }
 
@@ -28,6 +46,64 @@ func (sc *ServiceCenter) Get(clusterName string) (*ServerCluster, bool) {
 	return v.(*ServerCluster), true
 }
 
+//Unregister remove the server with specific name from center
+//then its pool is drained and closed , it waits until all streams are released or ctx is done
+//connections are closed even if ctx is done first and the error of Shutdown is returned
+func (sc *ServiceCenter) Unregister(ctx context.Context, clusterName string) error {
+	sc.lock.Lock()
+	v, ok := sc.clusters.Load(clusterName)
+	sc.clusters.Delete(clusterName)
+	sc.lock.Unlock()
+	if !ok {
+		return ErrClusterNotFound
+	}
+	return v.(*ServerCluster).Pool.Shutdown(ctx)
+}
+
+//Names return the sorted names of registered servers
+func (sc *ServiceCenter) Names() []string {
+	var names []string
+	sc.clusters.Range(func(key, value interface{}) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+//Range call fn for each registered server until fn returns false
+func (sc *ServiceCenter) Range(fn func(server *ServerCluster) bool) {
+	sc.clusters.Range(func(key, value interface{}) bool {
+		return fn(value.(*ServerCluster))
+	})
+}
+
+//Close unregister every server and shutdown their pools concurrently
+//discovery watched by the center is stopped
+//pools are drained until ctx is done and then closed , ctx.Err() is returned if they are not drained in time
+func (sc *ServiceCenter) Close(ctx context.Context) error {
+	sc.closed.Do(func() {
+		close(sc.doneChan())
+	})
+	var pools []*GRPCPool
+	sc.lock.Lock()
+	sc.clusters.Range(func(key, value interface{}) bool {
+		sc.clusters.Delete(key)
+		pools = append(pools, value.(*ServerCluster).Pool)
+		return true
+	})
+	sc.lock.Unlock()
+	return shutdownPools(ctx, pools)
+}
+
+//doneChan return the channel closed by Close
+func (sc *ServiceCenter) doneChan() chan struct{} {
+	sc.doneOnce.Do(func() {
+		sc.done = make(chan struct{})
+	})
+	return sc.done
+}
+
 //UnsafeGet return a server without ensure exist
 // ** make sure the server exist before use this method **
 func (sc *ServiceCenter) UnsafeGet(clusterName string) *ServerCluster {
@@ -35,11 +111,61 @@ func (sc *ServiceCenter) UnsafeGet(clusterName string) *ServerCluster {
 	return v.(*ServerCluster)
 }
 
+//UpdateTargets replace targets of the cluster with specific name , see GRPCPool.UpdateTargets
+func (sc *ServiceCenter) UpdateTargets(clusterName string, targets []string) error {
+	server, ok := sc.Get(clusterName)
+	if !ok {
+		return ErrClusterNotFound
+	}
+	return server.UpdateTargets(targets)
+}
+
+//SetTargetsUpdated set the hook called after each update of Watch
+func (sc *ServiceCenter) SetTargetsUpdated(fn TargetsUpdatedFunc) {
+	sc.targetsUpdated.Store(fn)
+}
+
+//Watch apply targets from d to the clusters with matching names until ctx is done or the center is closed
+//clusters missing in d or with empty targets are left unchanged
+func (sc *ServiceCenter) Watch(ctx context.Context, d ClusterDiscovery) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-sc.doneChan():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	updates := d.Watch(ctx)
+	go func() {
+		for mapping := range updates {
+			names := make([]string, 0, len(mapping))
+			for name := range mapping {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				targets := mapping[name]
+				if len(targets) == 0 {
+					continue
+				}
+				err := sc.UpdateTargets(name, targets)
+				if fn, ok := sc.targetsUpdated.Load().(TargetsUpdatedFunc); ok && fn != nil {
+					fn(name, targets, err)
+				}
+			}
+		}
+	}()
+}
+
 //ServerCluster is a manager of one physical server
 type ServerCluster struct {
 	Name          string
 	Pool          *GRPCPool
+	lock          sync.RWMutex
 	clientBuilder map[string]ServerBuilderFunc
+	//config is the configuration the cluster is built from , nil when built in code
+	config *ClusterConfig
 }
 
 //GetClient return a *GrpcConn
@@ -52,36 +178,89 @@ func (server *ServerCluster) GetClient() (*GrpcConn, error) {
 	return conn, nil
 }
 
+//GetClientContext return a *GrpcConn like GetClient
+//it waits for an available connection until ctx is done
+func (server *ServerCluster) GetClientContext(ctx context.Context) (*GrpcConn, error) {
+	conn, err := server.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
 //GetServerClient return grpc server client  release function and error
 //client is a interface , it can be available after assert to your own type of client
-// release is the GrpcConn.Release function  should be execute after all requests
+// release is the Lease.Release function  should be execute after all requests , calling it again is a no-op
 func (server *ServerCluster) GetServerClient(servname string) (client interface{}, release func(), err error) {
 
-	if len(server.clientBuilder) == 0 {
-		return nil, nil, ErrClientBuilderNil
+	builder, err := server.getClientBuilder(servname)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	var builder ServerBuilderFunc
-	var ok bool
-	if builder, ok = server.clientBuilder[servname]; !ok {
-		return nil, nil, ErrServerBuilderNil
+	lease, err := server.Pool.lease(context.Background(), false)
+	if err != nil {
+		return nil, nil, err
 	}
-	if builder == nil {
-		return nil, nil, ErrServerBuilderNil
+
+	client = builder(lease.Conn())
+	release = lease.Release
+	return
+}
+
+//GetServerClientContext is like GetServerClient
+//but the connection acquisition is bounded by ctx , so request deadlines cover it too
+func (server *ServerCluster) GetServerClientContext(ctx context.Context, servname string) (client interface{}, release func(), err error) {
+
+	builder, err := server.getClientBuilder(servname)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	conn, err := server.GetClient()
+	lease, err := server.Pool.lease(ctx, true)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	client = builder(conn.conn)
-	release = conn.Release
+	client = builder(lease.Conn())
+	release = lease.Release
 	return
 }
 
+//getClientBuilder return the builder registered with servname
+func (server *ServerCluster) getClientBuilder(servname string) (ServerBuilderFunc, error) {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	if len(server.clientBuilder) == 0 {
+		return nil, ErrClientBuilderNil
+	}
+
+	builder, ok := server.clientBuilder[servname]
+	if !ok || builder == nil {
+		return nil, ErrServerBuilderNil
+	}
+	return builder, nil
+}
+
+//SetPicker set the strategy of choosing connections of the cluster pool
+func (server *ServerCluster) SetPicker(picker Picker) {
+	server.Pool.SetPicker(picker)
+}
+
+//Resize change the cap of the cluster pool at runtime , see GRPCPool.Resize
+func (server *ServerCluster) Resize(n int) error {
+	return server.Pool.Resize(n)
+}
+
+//UpdateTargets replace targets of the cluster pool at runtime , see GRPCPool.UpdateTargets
+func (server *ServerCluster) UpdateTargets(targets []string) error {
+	return server.Pool.UpdateTargets(targets)
+}
+
 //SetClientBuilder set single server client builder
 func (server *ServerCluster) SetClientBuilder(servname string, fn ServerBuilderFunc) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
 	server.clientBuilder[servname] = fn
 }
 
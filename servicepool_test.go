@@ -1,7 +1,9 @@
 package pool
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
@@ -15,7 +17,7 @@ func TestNewServerCluster(t *testing.T) {
 
 	assert.Equal(t, "server1", sc.Name)
 	assert.Equal(t, 10, sc.Pool.Cap())
-	assert.Equal(t, 2, len(sc.Pool.dialOptions))
+	assert.Equal(t, 2, len(sc.Pool.GetDialOptions()))
 }
 
 func TestServerCluster_GetClient(t *testing.T) {
@@ -56,6 +58,211 @@ func TestServerCluster_GetClient(t *testing.T) {
 
 }
 
+func TestServerCluster_GetServerClientContext(t *testing.T) {
+	opt, _ := NewOptions(10, []string{"127.0.0.1:9999"})
+	sc, err := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, _, err = sc.GetServerClientContext(ctx, "default")
+	assert.Equal(t, ErrClientBuilderNil, err)
+
+	sc.SetClientBuilder("default", clientBuilder)
+
+	conn, err := sc.GetClientContext(ctx)
+	assert.Nil(t, err)
+	assert.IsType(t, (*GrpcConn)(nil), conn)
+
+	client, release, err := sc.GetServerClientContext(ctx, "default")
+	assert.Nil(t, err)
+	assert.IsType(t, (*testingBuilder)(nil), client)
+	release()
+
+	_, _, err = sc.GetServerClientContext(ctx, "default0")
+	assert.Equal(t, ErrServerBuilderNil, err)
+
+	sc.Pool.Close()
+	_, _, err = sc.GetServerClientContext(ctx, "default")
+	assert.Equal(t, ErrPoolClosed, err)
+}
+
+func TestServerCluster_Resize(t *testing.T) {
+	opt, _ := NewOptions(10, []string{"127.0.0.1:9999"})
+	sc, _ := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	defer sc.Pool.Close()
+
+	assert.Nil(t, sc.Resize(5))
+	assert.Equal(t, 5, sc.Pool.Cap())
+	assert.Equal(t, ErrOptionValid, sc.Resize(-1))
+}
+
+func TestServerCluster_UpdateTargets(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{"127.0.0.1:9999"})
+	sc, _ := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	defer sc.Pool.Close()
+
+	assert.Nil(t, sc.UpdateTargets([]string{addr}))
+	assert.Equal(t, []string{addr}, sc.Pool.GetOptions().Targets)
+	conn, err := sc.GetClient()
+	assert.Nil(t, err)
+	assert.Equal(t, addr, conn.Target())
+	conn.Release()
+	assert.Equal(t, ErrTargetInvalid, sc.UpdateTargets([]string{addr + ";weight=x"}))
+}
+
+func TestServiceCenter_UpdateTargets(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{"127.0.0.1:9999"})
+	cluster, _ := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	defer cluster.Pool.Close()
+	sc := &ServiceCenter{}
+	sc.Register(cluster)
+
+	assert.Nil(t, sc.UpdateTargets("server1", []string{addr}))
+	assert.Equal(t, []Target{{addr, 1}}, cluster.Pool.Targets())
+	assert.Equal(t, ErrClusterNotFound, sc.UpdateTargets("server2", []string{addr}))
+}
+
+type fakeClusterDiscovery chan map[string][]string
+
+func (d fakeClusterDiscovery) Watch(ctx context.Context) <-chan map[string][]string {
+	return d
+}
+
+func TestServiceCenter_Watch(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{"127.0.0.1:9999"})
+	cluster, _ := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	defer cluster.Pool.Close()
+	sc := &ServiceCenter{}
+	sc.Register(cluster)
+
+	type update struct {
+		name    string
+		targets []string
+		err     error
+	}
+	updated := make(chan update, 4)
+	sc.SetTargetsUpdated(func(name string, targets []string, err error) {
+		updated <- update{name, targets, err}
+	})
+	d := make(fakeClusterDiscovery)
+	sc.Watch(context.Background(), d)
+	defer close(d)
+
+	d <- map[string][]string{"server1": {addr}, "server2": {addr}, "server3": {}}
+	assert.Equal(t, update{"server1", []string{addr}, nil}, <-updated)
+	assert.Equal(t, update{"server2", []string{addr}, ErrClusterNotFound}, <-updated)
+	assert.Equal(t, []Target{{addr, 1}}, cluster.Pool.Targets())
+
+	d <- map[string][]string{"server1": {"a;weight=x"}}
+	assert.Equal(t, update{"server1", []string{"a;weight=x"}, ErrTargetInvalid}, <-updated)
+	assert.Equal(t, []Target{{addr, 1}}, cluster.Pool.Targets())
+}
+
+func TestServiceCenter_Unregister(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{addr})
+	sc := &ServiceCenter{}
+	for _, name := range []string{"server2", "server1", "server3"} {
+		cluster, _ := NewServerCluster(name, *opt, []grpc.DialOption{grpc.WithInsecure()})
+		sc.Register(cluster)
+	}
+	assert.Equal(t, []string{"server1", "server2", "server3"}, sc.Names())
+
+	count := 0
+	sc.Range(func(server *ServerCluster) bool {
+		count++
+		return false
+	})
+	assert.Equal(t, 1, count)
+
+	//Unregister waits until streams of the pool are released
+	s1 := sc.UnsafeGet("server1")
+	conn, err := s1.GetClient()
+	assert.Nil(t, err)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		conn.Release()
+	}()
+	assert.Nil(t, sc.Unregister(context.Background(), "server1"))
+	assert.Equal(t, 0, s1.Pool.Len())
+	assert.Equal(t, ErrClusterNotFound, sc.Unregister(context.Background(), "server1"))
+	assert.Equal(t, []string{"server2", "server3"}, sc.Names())
+	_, err = s1.GetClient()
+	assert.Equal(t, ErrPoolClosed, err)
+
+	//the pool is closed when ctx is done first and the error is returned
+	s2 := sc.UnsafeGet("server2")
+	conn, err = s2.GetClient()
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, sc.Unregister(ctx, "server2"))
+	assert.Equal(t, 0, s2.Pool.Len())
+	conn.Release()
+	assert.Equal(t, []string{"server3"}, sc.Names())
+
+	assert.Nil(t, sc.Close(context.Background()))
+	assert.Nil(t, sc.Names())
+}
+
+func TestServiceCenter_Close(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	opt, _ := NewOptions(2, []string{addr})
+	sc := &ServiceCenter{}
+	s1, _ := NewServerCluster("server1", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	sc.Register(s1)
+	s2, _ := NewServerCluster("server2", *opt, []grpc.DialOption{grpc.WithInsecure()})
+	sc.Register(s2)
+
+	d := make(fakeClusterDiscovery)
+	watched := make(chan struct{})
+	sc.Watch(context.Background(), watchedClusterDiscovery{d, watched})
+
+	conn, err := s1.GetClient()
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, sc.Close(ctx))
+	conn.Release()
+
+	//every pool is closed and discovery is stopped
+	_, err = s1.GetClient()
+	assert.Equal(t, ErrPoolClosed, err)
+	_, err = s2.GetClient()
+	assert.Equal(t, ErrPoolClosed, err)
+	assert.Nil(t, sc.Names())
+	select {
+	case <-watched:
+	case <-time.After(time.Second):
+		t.Fatal("discovery is not stopped")
+	}
+	assert.Nil(t, sc.Close(context.Background()))
+}
+
+//watchedClusterDiscovery close done when the watch is stopped
+type watchedClusterDiscovery struct {
+	fakeClusterDiscovery
+	done chan struct{}
+}
+
+func (d watchedClusterDiscovery) Watch(ctx context.Context) <-chan map[string][]string {
+	go func() {
+		<-ctx.Done()
+		close(d.done)
+	}()
+	return d.fakeClusterDiscovery
+}
+
 type iTestingBuilder interface {
 	Read()
 	Write()
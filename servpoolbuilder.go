@@ -1,6 +1,8 @@
 package pool
 
 import (
+	"context"
+
 	"google.golang.org/grpc"
 )
 
@@ -10,6 +12,8 @@ type ServiceCenterBuilder struct {
 	defaultOptions     *Options
 	defaultGrpcOptions []grpc.DialOption
 	clusters           []*ServerCluster
+	discovery          ClusterDiscovery
+	targetsUpdated     TargetsUpdatedFunc
 }
 
 //SetDefaultOptions set GRPC Pool Options
@@ -36,13 +40,7 @@ func (scb *ServiceCenterBuilder) SetServer(name string, builders map[string]Serv
 
 //SetServerWithDefaultOptions set Server Cluster use default
 func (scb *ServiceCenterBuilder) SetServerWithDefaultOptions(name string, builders map[string]ServerBuilderFunc, targets ...string) error {
-	opt := Options{}
-	opt.Cap = scb.defaultOptions.Cap
-	opt.ForcePermit = scb.defaultOptions.ForcePermit
-	opt.ClientKeepAlive = scb.defaultOptions.ClientKeepAlive
-	opt.PingTimeout = scb.defaultOptions.PingTimeout
-	opt.IdleTimeout = scb.defaultOptions.IdleTimeout
-	opt.DialTimeout = scb.defaultOptions.DialTimeout
+	opt := *scb.defaultOptions
 	opt.Targets = targets
 
 	grpcOptions := make([]grpc.DialOption, 0)
@@ -58,12 +56,26 @@ func (scb *ServiceCenterBuilder) SetServerWithDefaultOptions(name string, builde
 	return nil
 }
 
+//SetDiscovery set the source of cluster targets watched by the built Service Center
+//fn is called after each update , it can be nil
+func (scb *ServiceCenterBuilder) SetDiscovery(d ClusterDiscovery, fn TargetsUpdatedFunc) {
+	scb.discovery = d
+	scb.targetsUpdated = fn
+}
+
 //Build return *ServiceCenter
+//the discovery is watched for the lifetime of the Service Center when it is set
 func (scb *ServiceCenterBuilder) Build() *ServiceCenter {
 	sc := &ServiceCenter{}
 	for _, c := range scb.clusters {
 		sc.Register(c)
 	}
+	if scb.targetsUpdated != nil {
+		sc.SetTargetsUpdated(scb.targetsUpdated)
+	}
+	if scb.discovery != nil {
+		sc.Watch(context.Background(), scb.discovery)
+	}
 	return sc
 }
 
@@ -1,7 +1,12 @@
 package pool
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
@@ -40,3 +45,36 @@ func TestNewServiceCenterBuilder(t *testing.T) {
 	assert.IsType(t, func() {}, release)
 
 }
+
+func TestServiceCenterBuilder_SetDiscovery(t *testing.T) {
+	addr, stop := startTestingServer(t)
+	defer stop()
+	dir, err := ioutil.TempDir("", "grpcpool")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clusters.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"server1": ["`+addr+`"]}`), 0644))
+
+	scb := NewServiceCenterBuilder()
+	assert.Nil(t, scb.SetServerWithDefaultOptions("server1", nil, "127.0.0.1:8899"))
+	d := NewClusterFileDiscovery(path)
+	d.Interval = 5 * time.Millisecond
+	updated := make(chan error, 1)
+	scb.SetDiscovery(d, func(name string, targets []string, err error) {
+		updated <- err
+	})
+	sc := scb.Build()
+	defer sc.Close(context.Background())
+	s1 := sc.UnsafeGet("server1")
+
+	select {
+	case err := <-updated:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("targets not updated")
+	}
+	conn, err := s1.GetClient()
+	assert.Nil(t, err)
+	assert.Equal(t, addr, conn.Target())
+	conn.Release()
+}
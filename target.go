@@ -0,0 +1,366 @@
+package pool
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//maxTargetWeight is the largest weight of a target
+const maxTargetWeight = 10000
+
+//Target is a weighted target address
+//it is written as "host:port;weight=N" in Options.Targets , weight is 1 by default
+//connections are spread across targets in proportion to weight , so are requests with the default Picker
+//targets with weight 0 only serve requests when no others have room
+type Target struct {
+	Addr   string
+	Weight int
+}
+
+//String return the target in Options.Targets syntax
+func (t Target) String() string {
+	if t.Weight == 1 {
+		return t.Addr
+	}
+	return t.Addr + ";weight=" + strconv.Itoa(t.Weight)
+}
+
+//ParseTarget parse a target written as "host:port" or "host:port;weight=N"
+func ParseTarget(s string) (Target, error) {
+	parts := strings.Split(s, ";")
+	t := Target{Addr: strings.TrimSpace(parts[0]), Weight: 1}
+	if t.Addr == "" {
+		return t, ErrTargetEmpty
+	}
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != "weight" {
+			return t, ErrTargetInvalid
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || weight < 0 || weight > maxTargetWeight {
+			return t, ErrTargetInvalid
+		}
+		t.Weight = weight
+	}
+	return t, nil
+}
+
+//parseTargets parse every target , duplicated addresses are merged by adding weights
+//empty targets are skipped
+func parseTargets(targets []string) ([]Target, error) {
+	parsed := make([]Target, 0, len(targets))
+	index := make(map[string]int, len(targets))
+	for _, s := range targets {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		t, err := ParseTarget(s)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[t.Addr]; ok {
+			parsed[i].Weight += t.Weight
+			continue
+		}
+		index[t.Addr] = len(parsed)
+		parsed = append(parsed, t)
+	}
+	return parsed, nil
+}
+
+//targetSet is a snapshot of pool targets , only the smooth weighted round-robin state changes
+type targetSet struct {
+	//raw are the targets as given in options
+	raw     []string
+	targets []Target
+	//index is the position of each address in targets
+	index map[string]int
+	//weights are the effective weights , every target has weight 1 when all weights are 0
+	weights []int
+	total   int
+	//lock protects current
+	lock sync.Mutex
+	//current are the smooth weighted round-robin weights of targets
+	current []int
+}
+
+//newTargetSet return the targetSet of targets parsed from raw
+func newTargetSet(raw []string, targets []Target) *targetSet {
+	ts := &targetSet{
+		raw:     raw,
+		targets: targets,
+		index:   make(map[string]int, len(targets)),
+		weights: make([]int, len(targets)),
+		current: make([]int, len(targets)),
+	}
+	for i, t := range targets {
+		ts.index[t.Addr] = i
+		ts.weights[i] = t.Weight
+		ts.total += t.Weight
+	}
+	if ts.total == 0 {
+		for i := range ts.weights {
+			ts.weights[i] = 1
+		}
+		ts.total = len(targets)
+	}
+	return ts
+}
+
+//schedule return the index of target to serve the next request by smooth weighted round-robin
+//it returns -1 when there is no target
+func (ts *targetSet) schedule() int {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	best := -1
+	for i, w := range ts.weights {
+		if w == 0 {
+			continue
+		}
+		ts.current[i] += w
+		if best < 0 || ts.current[i] > ts.current[best] {
+			best = i
+		}
+	}
+	if best >= 0 {
+		ts.current[best] -= ts.total
+	}
+	return best
+}
+
+//next return the index of target which needs a connection most by weight
+//counts are the connections of each address , it returns -1 when there is no target
+func (ts *targetSet) next(counts map[string]int) int {
+	best := -1
+	for i, t := range ts.targets {
+		w := ts.weights[i]
+		if w == 0 {
+			continue
+		}
+		//(count+1)/weight is the smallest
+		if best < 0 || (counts[t.Addr]+1)*ts.weights[best] < (counts[ts.targets[best].Addr]+1)*w {
+			best = i
+		}
+	}
+	return best
+}
+
+//allocate return the number of connections of each address when pool has n connections
+func (ts *targetSet) allocate(n int) map[string]int {
+	counts := make(map[string]int, len(ts.targets))
+	if len(ts.targets) == 0 {
+		return counts
+	}
+	for ; n > 0; n-- {
+		counts[ts.targets[ts.next(counts)].Addr]++
+	}
+	return counts
+}
+
+//storeTargets replace targets of pool
+func (p *GRPCPool) storeTargets(raw []string, targets []Target) {
+	p.targets.Store(newTargetSet(raw, targets))
+}
+
+//loadTargets return the current targets of pool
+func (p *GRPCPool) loadTargets() *targetSet {
+	return p.targets.Load().(*targetSet)
+}
+
+//Targets return the current targets of pool
+func (p *GRPCPool) Targets() []Target {
+	targets := p.loadTargets().targets
+	return append(make([]Target, 0, len(targets)), targets...)
+}
+
+//PoolStats is a snapshot of pool usage
+type PoolStats struct {
+	//Len is the number of connections in pool
+	Len int
+	//Cap is the current capacity of pool
+	Cap int
+	//Pending is the number of connections being dialed
+	Pending int
+	//InUse is the number of streams in use
+	InUse int64
+	//Targets is the breakdown by target address
+	Targets map[string]TargetStats
+}
+
+//TargetStats is the usage of connections to one target
+type TargetStats struct {
+	//Conns is the number of connections to the target
+	Conns int
+	//InUse is the number of streams in use on connections to the target
+	InUse int64
+}
+
+//Stats return a snapshot of pool usage with per-target breakdown
+//each target of pool is present by address even if it has no connection
+func (p *GRPCPool) Stats() PoolStats {
+	conns := p.conns()
+	stats := PoolStats{
+		Len:     len(conns),
+		Cap:     p.Cap(),
+		Pending: int(atomic.LoadInt32(&p.pending)),
+	}
+	targets := p.loadTargets().targets
+	stats.Targets = make(map[string]TargetStats, len(targets))
+	for _, target := range targets {
+		stats.Targets[target.Addr] = TargetStats{}
+	}
+	for _, conn := range conns {
+		refs := conn.RefCount()
+		ts := stats.Targets[conn.Target()]
+		ts.Conns++
+		ts.InUse += refs
+		stats.Targets[conn.Target()] = ts
+		stats.InUse += refs
+	}
+	return stats
+}
+
+//nextDialTarget return the target address with the fewest connections including pending dials
+//in proportion to its weight , targets with weight 0 are not dialed unless all are 0
+//must be called with p.lock held
+func (p *GRPCPool) nextDialTarget() string {
+	ts := p.loadTargets()
+	if len(ts.targets) == 0 {
+		return ""
+	}
+	counts := make(map[string]int, len(ts.targets))
+	for _, conn := range p.conns() {
+		//a conn being replaced leaves pool after its replacement is put into pool
+		if !p.replacing(conn) {
+			counts[conn.Target()]++
+		}
+	}
+	for _, call := range p.dialing {
+		counts[call.target]++
+	}
+	return ts.targets[ts.next(counts)].Addr
+}
+
+//UpdateTargets replace targets of pool at runtime
+//connections to removed targets and surplus connections of remaining targets are replaced by weight ,
+//replacements are dialed in background and the replaced connections keep serving until their replacement
+//is put into pool , then they are drained and closed after their streams are released
+//a failed replacement keeps the replaced connection and is retried later , so Get keeps serving throughout
+func (p *GRPCPool) UpdateTargets(targets []string) error {
+	parsed, err := parseTargets(targets)
+	if err != nil {
+		return err
+	}
+	if len(parsed) == 0 {
+		return ErrTargetEmpty
+	}
+	raw := append(make([]string, 0, len(targets)), targets...)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.isClosed() {
+		return ErrPoolClosed
+	}
+	p.storeTargets(raw, parsed)
+	p.rebalance()
+	return nil
+}
+
+//retryRebalance rebalance again after a replacement dial fails
+func (p *GRPCPool) retryRebalance() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.isClosed() {
+		p.rebalance()
+	}
+}
+
+//rebalance replace the least loaded connections of targets having more than their share
+//connections of removed targets are all replaced , replacements are dialed to targets below their share
+//only connections of removed targets are replaced when connFactory chooses target itself
+//connections dialed with replaced settings are all replaced too
+//must be called with p.lock held
+func (p *GRPCPool) rebalance() {
+	ts := p.loadTargets()
+	groups := make(map[string][]*GrpcConn)
+	var stale []*GrpcConn
+	for _, conn := range p.conns() {
+		//conns being replaced are leaving already
+		if p.replacing(conn) {
+			continue
+		}
+		if conn.generation != p.generation {
+			stale = append(stale, conn)
+			continue
+		}
+		groups[conn.Target()] = append(groups[conn.Target()], conn)
+	}
+	for _, conn := range stale {
+		p.startReplace(conn)
+	}
+	desired := ts.allocate(p.Len())
+	for target, conns := range groups {
+		surplus := len(conns) - desired[target]
+		if _, ok := ts.index[target]; ok && p.untargeted {
+			surplus = 0
+		}
+		if surplus <= 0 {
+			continue
+		}
+		sort.Slice(conns, func(i, j int) bool {
+			return conns[i].RefCount() < conns[j].RefCount()
+		})
+		for _, conn := range conns[:surplus] {
+			p.startReplace(conn)
+		}
+	}
+}
+
+//pickBalanced choose the next target by weight and pick a connection having room of it
+//the other weighted targets are tried in order when it has no room , then all connections
+//targets are not scheduled when ts is nil , so picker chooses among all connections having room
+//candidates is the reusable buffer , it is returned cleared
+func (p *GRPCPool) pickBalanced(conns []*GrpcConn, ts *targetSet, maxStreams int64, picker Picker, candidates []*GrpcConn) (*GrpcConn, []*GrpcConn) {
+	n, start := 0, 0
+	if ts != nil {
+		n = len(ts.targets)
+		start = ts.schedule()
+	}
+	var conn *GrpcConn
+	used := 0
+	//the last round is for all connections
+	for i := 0; i <= n && conn == nil; i++ {
+		target := ""
+		if i < n {
+			index := (start + i) % n
+			if ts.weights[index] == 0 {
+				continue
+			}
+			target = ts.targets[index].Addr
+		}
+		candidates = candidates[:0]
+		for _, c := range conns {
+			if target != "" && c.Target() != target {
+				continue
+			}
+			if maxStreams == 0 || c.RefCount() < maxStreams {
+				candidates = append(candidates, c)
+			}
+		}
+		if len(candidates) > used {
+			used = len(candidates)
+		}
+		if len(candidates) > 0 {
+			conn = picker.Pick(candidates)
+		}
+	}
+	candidates = candidates[:used]
+	for i := range candidates {
+		candidates[i] = nil
+	}
+	return conn, candidates[:0]
+}
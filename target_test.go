@@ -0,0 +1,383 @@
+package pool
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func createMultiTargetPool(t *testing.T, cap int, maxStreams int) (*GRPCPool, []string, func()) {
+	addr1, stop1 := startTestingServer(t)
+	addr2, stop2 := startTestingServer(t)
+	addr3, stop3 := startTestingServer(t)
+	targets := []string{addr1, addr2, addr3}
+	opt, _ := NewOptions(cap, targets)
+	opt.MaxStreamsPerConn = maxStreams
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	return pool, targets, func() {
+		pool.Close()
+		stop1()
+		stop2()
+		stop3()
+	}
+}
+
+func TestGRPCPool_TargetsBalancedDial(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 6, 0)
+	defer stop()
+
+	assert.Nil(t, pool.InitConnections())
+	stats := pool.Stats()
+	assert.Equal(t, 6, stats.Len)
+	assert.Equal(t, 6, stats.Cap)
+	assert.Len(t, stats.Targets, 3)
+	for _, target := range targets {
+		assert.Equal(t, 2, stats.Targets[target].Conns)
+	}
+}
+
+func TestGRPCPool_TargetsBalancedPick(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 4, 0)
+	defer stop()
+	assert.Nil(t, pool.InitConnections())
+
+	conns := make([]*GrpcConn, 0, 30)
+	for i := 0; i < 30; i++ {
+		conn, err := pool.Get()
+		assert.Nil(t, err)
+		conns = append(conns, conn)
+	}
+	//targets are picked in turn even though one target has more connections
+	stats := pool.Stats()
+	assert.EqualValues(t, 30, stats.InUse)
+	for _, target := range targets {
+		assert.EqualValues(t, 10, stats.Targets[target].InUse)
+	}
+
+	for _, conn := range conns {
+		conn.Release()
+	}
+	stats = pool.Stats()
+	assert.EqualValues(t, 0, stats.InUse)
+	assert.EqualValues(t, 0, stats.Targets[targets[0]].InUse)
+}
+
+func TestGRPCPool_TargetsCustomPicker(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 4, 0)
+	defer stop()
+	assert.Nil(t, pool.InitConnections())
+
+	//a Picker set by SetPicker chooses among connections of all targets
+	pool.SetPicker(NewLeastRefCountPicker())
+	conns := make([]*GrpcConn, 0, 40)
+	for i := 0; i < 40; i++ {
+		conn, err := pool.Get()
+		assert.Nil(t, err)
+		conns = append(conns, conn)
+	}
+	for _, conn := range pool.conns() {
+		assert.EqualValues(t, 10, conn.RefCount())
+	}
+	for _, conn := range conns {
+		conn.Release()
+	}
+
+	pool.SetPicker(NewWeightedPicker(map[string]int{targets[1]: 0, targets[2]: 0}))
+	for i := 0; i < 10; i++ {
+		conn, err := pool.Get()
+		assert.Nil(t, err)
+		assert.Equal(t, targets[0], conn.Target())
+		conn.Release()
+	}
+}
+
+func TestGRPCPool_TargetsFullFallback(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 3, 2)
+	defer stop()
+	assert.Nil(t, pool.InitConnections())
+
+	//fill connections of the first target , others are still picked
+	first := pool.conns()[0]
+	assert.True(t, first.tryUse(2))
+	assert.True(t, first.tryUse(2))
+	for i := 0; i < 4; i++ {
+		conn, err := pool.Get()
+		assert.Nil(t, err)
+		assert.True(t, first != conn)
+	}
+	_, err := pool.Get()
+	assert.Equal(t, ErrPoolExhausted, err)
+
+	stats := pool.Stats()
+	assert.EqualValues(t, 6, stats.InUse)
+	for _, target := range targets {
+		assert.EqualValues(t, 2, stats.Targets[target].InUse)
+	}
+}
+
+func TestGRPCPool_SetTargetConnFactory(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 6, 0)
+	defer stop()
+
+	var lock sync.Mutex
+	dialed := map[string]int{}
+	pool.SetTargetConnFactory(func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		lock.Lock()
+		dialed[target]++
+		lock.Unlock()
+		if target == targets[2] {
+			return nil, errors.New("refused")
+		}
+		return grpc.Dial(target, p.GetDialOptions()...)
+	})
+	assert.NotNil(t, pool.InitConnections())
+	lock.Lock()
+	defer lock.Unlock()
+	for _, target := range targets {
+		assert.Equal(t, 2, dialed[target])
+	}
+}
+
+func TestGRPCPool_SetConnFactoryTarget(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 2, 0)
+	defer stop()
+
+	//conns of legacy factory are counted by the target they dialed
+	pool.SetConnFactory(func(p *GRPCPool) (*grpc.ClientConn, error) {
+		return grpc.Dial(targets[1], p.GetDialOptions()...)
+	})
+	assert.Nil(t, pool.InitConnections())
+	stats := pool.Stats()
+	assert.Equal(t, 2, stats.Targets[targets[1]].Conns)
+	assert.Equal(t, 0, stats.Targets[targets[0]].Conns)
+
+	conn, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, targets[1], conn.Target())
+	conn.Release()
+}
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("127.0.0.1:80")
+	assert.Nil(t, err)
+	assert.Equal(t, Target{Addr: "127.0.0.1:80", Weight: 1}, target)
+	assert.Equal(t, "127.0.0.1:80", target.String())
+
+	target, err = ParseTarget("127.0.0.1:80; weight=3")
+	assert.Nil(t, err)
+	assert.Equal(t, Target{Addr: "127.0.0.1:80", Weight: 3}, target)
+	assert.Equal(t, "127.0.0.1:80;weight=3", target.String())
+
+	target, err = ParseTarget("127.0.0.1:80;weight=0")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, target.Weight)
+
+	_, err = ParseTarget(";weight=1")
+	assert.Equal(t, ErrTargetEmpty, err)
+	for _, s := range []string{"127.0.0.1:80;weight=-1", "127.0.0.1:80;weight=a", "127.0.0.1:80;w=1", "127.0.0.1:80;weight=10001"} {
+		_, err = ParseTarget(s)
+		assert.Equal(t, ErrTargetInvalid, err, s)
+	}
+
+	_, err = NewOptions(1, []string{"127.0.0.1:80;weight=a"})
+	assert.Equal(t, ErrTargetInvalid, err)
+
+	targets, err := parseTargets([]string{"a:1;weight=2", "", "b:1", "a:1"})
+	assert.Nil(t, err)
+	assert.Equal(t, []Target{{Addr: "a:1", Weight: 3}, {Addr: "b:1", Weight: 1}}, targets)
+}
+
+func TestNewTargetSet(t *testing.T) {
+	schedule := func(ts *targetSet, n int) []int {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = ts.schedule()
+		}
+		return order
+	}
+	//weights are smoothly interleaved
+	ts := newTargetSet(nil, []Target{{"a", 4}, {"b", 2}, {"c", 0}})
+	assert.Equal(t, []int{0, 1, 0, 0, 1, 0}, schedule(ts, 6))
+
+	ts = newTargetSet(nil, []Target{{"a", 5}, {"b", 1}, {"c", 1}})
+	assert.Equal(t, []int{0, 0, 1, 0, 2, 0, 0, 0, 0, 1, 0, 2, 0, 0}, schedule(ts, 14))
+
+	//all targets are used evenly when all weights are 0
+	ts = newTargetSet(nil, []Target{{"a", 0}, {"b", 0}})
+	assert.Equal(t, []int{1, 1}, ts.weights)
+	assert.Equal(t, []int{0, 1, 0, 1}, schedule(ts, 4))
+
+	assert.Equal(t, -1, newTargetSet(nil, nil).schedule())
+}
+
+func TestGRPCPool_TargetsWeighted(t *testing.T) {
+	addr1, stop1 := startTestingServer(t)
+	defer stop1()
+	addr2, stop2 := startTestingServer(t)
+	defer stop2()
+	addr3, stop3 := startTestingServer(t)
+	defer stop3()
+	opt, _ := NewOptions(4, []string{addr1 + ";weight=3", addr2, addr3 + ";weight=0"})
+	pool, err := NewGRPCPool(opt, grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	assert.Nil(t, pool.InitConnections())
+	assert.Equal(t, []Target{{addr1, 3}, {addr2, 1}, {addr3, 0}}, pool.Targets())
+	stats := pool.Stats()
+	assert.Equal(t, 3, stats.Targets[addr1].Conns)
+	assert.Equal(t, 1, stats.Targets[addr2].Conns)
+	assert.Equal(t, 0, stats.Targets[addr3].Conns)
+
+	conns := make([]*GrpcConn, 0, 40)
+	for i := 0; i < 40; i++ {
+		conn, err := pool.Get()
+		assert.Nil(t, err)
+		conns = append(conns, conn)
+	}
+	stats = pool.Stats()
+	assert.EqualValues(t, 30, stats.Targets[addr1].InUse)
+	assert.EqualValues(t, 10, stats.Targets[addr2].InUse)
+	for _, conn := range conns {
+		conn.Release()
+	}
+}
+
+func TestGRPCPool_UpdateTargets(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 4, 0)
+	defer stop()
+	assert.Nil(t, pool.UpdateTargets(targets[:2]))
+	assert.Nil(t, pool.InitConnections())
+	stats := pool.Stats()
+	assert.Equal(t, 2, stats.Targets[targets[0]].Conns)
+	assert.Equal(t, 2, stats.Targets[targets[1]].Conns)
+
+	//a stream on the removed target keeps its connection until released
+	var busy *GrpcConn
+	for busy == nil || busy.Target() != targets[0] {
+		if busy != nil {
+			busy.Release()
+		}
+		conn, err := pool.Get()
+		assert.Nil(t, err)
+		busy = conn
+	}
+
+	assert.Nil(t, pool.UpdateTargets([]string{targets[1], targets[2]}))
+	assert.Equal(t, []Target{{targets[1], 1}, {targets[2], 1}}, pool.Targets())
+	assert.Equal(t, []string{targets[1], targets[2]}, pool.GetOptions().Targets)
+	assert.Eventually(t, func() bool {
+		stats := pool.Stats()
+		return stats.Targets[targets[1]].Conns == 2 && stats.Targets[targets[2]].Conns == 2
+	}, time.Second, 5*time.Millisecond)
+	_, ok := pool.Stats().Targets[targets[0]]
+	assert.False(t, ok)
+
+	//Get keeps serving on the new targets
+	for i := 0; i < 8; i++ {
+		conn, err := pool.Get()
+		assert.Nil(t, err)
+		assert.NotEqual(t, targets[0], conn.Target())
+		conn.Release()
+	}
+
+	assert.NotEqual(t, connectivity.Shutdown, busy.Conn().GetState())
+	busy.Release()
+	assert.Eventually(t, func() bool {
+		return busy.Conn().GetState() == connectivity.Shutdown
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, ErrTargetEmpty, pool.UpdateTargets([]string{""}))
+	assert.Equal(t, ErrTargetInvalid, pool.UpdateTargets([]string{"a;weight=x"}))
+	pool.Close()
+	assert.Equal(t, ErrPoolClosed, pool.UpdateTargets(targets))
+}
+
+func TestGRPCPool_UpdateTargetsRebalance(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 4, 0)
+	defer stop()
+	assert.Nil(t, pool.UpdateTargets(targets[:1]))
+	assert.Nil(t, pool.InitConnections())
+	assert.Equal(t, 4, pool.Stats().Targets[targets[0]].Conns)
+
+	//the existing target hands over its share to the new one
+	assert.Nil(t, pool.UpdateTargets([]string{targets[0], targets[1] + ";weight=3"}))
+	assert.Eventually(t, func() bool {
+		stats := pool.Stats()
+		return stats.Targets[targets[0]].Conns == 1 && stats.Targets[targets[1]].Conns == 3
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestGRPCPool_UpdateTargetsPendingDial(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 1, 0)
+	defer stop()
+	assert.Nil(t, pool.UpdateTargets(targets[:1]))
+
+	//a dial to the removed target is dropped
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	pool.SetTargetConnFactory(func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		if target == targets[0] {
+			close(dialing)
+			<-release
+		}
+		return grpc.Dial(target, p.GetDialOptions()...)
+	})
+	pool.lock.Lock()
+	call := pool.startDial()
+	pool.lock.Unlock()
+	<-dialing
+	assert.Nil(t, pool.UpdateTargets(targets[1:2]))
+	close(release)
+	<-call.done
+	assert.Nil(t, call.conn)
+
+	conn, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, targets[1], conn.Target())
+	conn.Release()
+}
+
+func TestGRPCPool_UpdateTargetsMakeBeforeBreak(t *testing.T) {
+	pool, targets, stop := createMultiTargetPool(t, 2, 0)
+	defer stop()
+	assert.Nil(t, pool.UpdateTargets(targets[:1]))
+	assert.Nil(t, pool.InitConnections())
+	old := append([]*GrpcConn{}, pool.conns()...)
+
+	//dials to the new target fail until allowed
+	var allowed int32
+	pool.SetTargetConnFactory(func(p *GRPCPool, target string) (*grpc.ClientConn, error) {
+		if target == targets[1] && atomic.LoadInt32(&allowed) == 0 {
+			return nil, errors.New("unreachable")
+		}
+		return grpc.Dial(target, p.GetDialOptions()...)
+	})
+
+	//connections to the removed target keep serving while replacements fail
+	assert.Nil(t, pool.UpdateTargets(targets[1:2]))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 2, pool.Len())
+	for i := 0; i < 4; i++ {
+		conn, err := pool.Get()
+		assert.Nil(t, err)
+		assert.Equal(t, targets[0], conn.Target())
+		conn.Release()
+	}
+
+	//replacements are retried and then the old connections are closed
+	atomic.StoreInt32(&allowed, 1)
+	assert.Eventually(t, func() bool {
+		return pool.Stats().Targets[targets[1]].Conns == 2 && pool.Len() == 2
+	}, 3*replaceRetryDelay, 5*time.Millisecond)
+	for _, conn := range old {
+		assert.Equal(t, connectivity.Shutdown, conn.Conn().GetState())
+	}
+}